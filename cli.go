@@ -0,0 +1,306 @@
+//go:build !js && !wasm
+// +build !js,!wasm
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"project_two/core"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `usage: project_two [command] [flags]
+
+With no command, runs the default demo.
+
+Commands:
+  simulate  run a world for a number of ticks without rendering
+  render    run a world for a number of ticks and save an image of it
+  bench     time how long a world takes to simulate
+
+Run 'project_two [command] -h' for the flags of each command.
+`
+
+// runCommand runs a subcommand, returning the process exit code
+func runCommand(args []string) int {
+	commands := map[string]func(args []string) error{
+		"simulate": runSimulate,
+		"render":   runRender,
+		"bench":    runBench,
+	}
+	command, isCommand := commands[args[0]]
+	if !isCommand {
+		fmt.Fprint(os.Stderr, usage)
+		if args[0] == "-h" || args[0] == "help" {
+			return 0
+		}
+		return 2
+	}
+	if err := command(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		if err == errFlagParse {
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// errFlagParse is returned for invalid flags, which the flag package has already printed with the usage
+var errFlagParse = errors.New("invalid flags")
+
+// parseFlags parses a command's flags, returning flag.ErrHelp for -h or errFlagParse for invalid flags
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && err != flag.ErrHelp {
+		return errFlagParse
+	}
+	return err
+}
+
+// simulationFlags are the flags shared by every command that runs a world
+type simulationFlags struct {
+	worldPath string
+	ticks     int
+	mode      string
+	workers   int
+	qc        bool
+	inputPath string
+}
+
+func addSimulationFlags(fs *flag.FlagSet, s *simulationFlags, defaultTicks int) {
+	fs.StringVar(&s.worldPath, "world", "", "world file to load, the demo world if empty")
+	fs.IntVar(&s.ticks, "ticks", defaultTicks, "number of ticks to run")
+	fs.StringVar(&s.mode, "mode", "ca", "simulation mode, ca or ticks")
+	fs.IntVar(&s.workers, "workers", runtime.NumCPU(), "goroutines used to update the world")
+	fs.BoolVar(&s.qc, "qc", false, "enable quasi-connectivity")
+	fs.StringVar(&s.inputPath, "inputs", "", "input log to replay against the world, also sets the mode and rules it was recorded with")
+}
+
+func parseSimulationMode(s string) (core.SimulationMode, error) {
+	for _, mode := range [...]core.SimulationMode{core.CellularAutomata, core.ScheduledTicks} {
+		if strings.EqualFold(s, mode.String()) {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown simulation mode %q", s)
+}
+
+func loadWorld(path string) (core.World, error) {
+	if path == "" {
+		w := core.World{}
+		core.CreateWorld(&w)
+		return w, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return core.World{}, err
+	}
+	return core.DecodeWorld(data)
+}
+
+func saveWorld(path string, w *core.World) error {
+	data, err := core.EncodeWorld(w)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// setupScene sets the world and simulation options of scene from the flags
+func setupScene(scene *core.Scene, s simulationFlags) error {
+	mode, err := parseSimulationMode(s.mode)
+	if err != nil {
+		return err
+	}
+	w, err := loadWorld(s.worldPath)
+	if err != nil {
+		return err
+	}
+	if s.qc {
+		w.SetQuasiConnectivity(true)
+	}
+	scene.World = w
+	scene.GameState = core.Playing
+	scene.SimulationMode = mode
+	scene.SimulationWorkers = max(1, s.workers)
+	scene.PeriodDetector.Reset()
+	if s.inputPath != "" {
+		data, err := os.ReadFile(s.inputPath)
+		if err != nil {
+			return err
+		}
+		inputLog, err := core.DecodeInputLog(data)
+		if err != nil {
+			return err
+		}
+		scene.StartReplay(inputLog)
+	}
+	return nil
+}
+
+// step replays any inputs due on the current tick, then advances the simulation
+func step(scene *core.Scene) {
+	core.ReplayInputs(scene)
+	core.StepSimulation(scene)
+}
+
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	s := simulationFlags{}
+	addSimulationFlags(fs, &s, 100)
+	outPath := fs.String("out", "", "file to save the final world to, - for stdout")
+	printStats := fs.Bool("stats", false, "print CSV statistics for every tick")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	scene := core.Scene{}
+	if err := setupScene(&scene, s); err != nil {
+		return err
+	}
+
+	if *printStats {
+		fmt.Println("tick,updates,sub_updates,sub_iterations,unstable,period,stable")
+	}
+	numUnstableTicks := 0
+	for i := 0; i < s.ticks; i++ {
+		step(&scene)
+		if scene.SubUpdateOscillation != nil {
+			numUnstableTicks++
+		}
+		if *printStats {
+			fmt.Printf("%d,%d,%d,%d,%t,%d,%t\n",
+				scene.Iteration-1,
+				scene.NumBlockUpdatesInStep,
+				scene.NumBlockSubUpdatesInStep,
+				scene.NumBlockSubUpdateIterationsInStep,
+				scene.SubUpdateOscillation != nil,
+				scene.PeriodDetector.Period,
+				scene.PeriodDetector.IsStable,
+			)
+		}
+	}
+
+	d := &scene.PeriodDetector
+	period := fmt.Sprintf("period %d from tick %d", d.Period, d.CycleStart)
+	if d.IsStable {
+		period = fmt.Sprintf("stable from tick %d", d.CycleStart)
+	}
+	fmt.Fprintf(os.Stderr, "ran %d ticks, %d unstable, %s\n", s.ticks, numUnstableTicks, period)
+	if *outPath != "" {
+		return saveWorld(*outPath, &scene.World)
+	}
+	return nil
+}
+
+func parsePoint3D(s string) (core.Point3D, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return core.Point3D{}, fmt.Errorf("expected x,y,z got %q", s)
+	}
+	var xyz [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return core.Point3D{}, fmt.Errorf("expected x,y,z got %q", s)
+		}
+		xyz[i] = v
+	}
+	return core.Point3D{X: xyz[0], Y: xyz[1], Z: xyz[2]}, nil
+}
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	s := simulationFlags{}
+	addSimulationFlags(fs, &s, 0)
+	outPath := fs.String("out", "output/scene.png", "PNG file to save the image to")
+	size := fs.Int("size", 512, "width and height of the image")
+	position := fs.String("pos", "", "camera position x,y,z, the saved camera if empty")
+	rotation := fs.String("rot", "", "camera rotation x,y,z in degrees, the saved camera if empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, *size, *size))
+	scene := core.Scene{}
+	core.InitialiseScene(&scene, img, 1)
+	if err := setupScene(&scene, s); err != nil {
+		return err
+	}
+	if *position != "" {
+		p, err := parsePoint3D(*position)
+		if err != nil {
+			return err
+		}
+		scene.Camera.Position = p
+	}
+	if *rotation != "" {
+		r, err := parsePoint3D(*rotation)
+		if err != nil {
+			return err
+		}
+		scene.Camera.Rotation = core.Point3D{X: core.DegToRad(r.X), Y: core.DegToRad(r.Y), Z: core.DegToRad(r.Z)}
+	}
+
+	for i := 0; i < s.ticks; i++ {
+		step(&scene)
+	}
+	depthBuffer := make(core.DepthBuffer, *size**size)
+	core.DrawScene(&scene, img, &depthBuffer)
+
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
+
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	s := simulationFlags{}
+	addSimulationFlags(fs, &s, 1000)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	scene := core.Scene{}
+	if err := setupScene(&scene, s); err != nil {
+		return err
+	}
+	if s.ticks <= 0 {
+		return fmt.Errorf("ticks must be positive")
+	}
+
+	start := time.Now()
+	for i := 0; i < s.ticks; i++ {
+		step(&scene)
+	}
+	elapsed := time.Since(start)
+
+	fmt.Printf("mode %s, workers %d, %d ticks in %v, %v per tick, %.0f ticks per second\n",
+		scene.SimulationMode,
+		scene.SimulationWorkers,
+		s.ticks,
+		elapsed,
+		elapsed/time.Duration(s.ticks),
+		float64(s.ticks)/elapsed.Seconds(),
+	)
+	return nil
+}
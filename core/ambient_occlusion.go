@@ -0,0 +1,64 @@
+package core
+
+// noOcclusion is the ambient occlusion of a triangle with no vertices darkened
+var noOcclusion = [3]float64{1, 1, 1}
+
+// aoBrightness is the brightness of a face's corner, indexed by the number of its three
+// neighbouring blocks in front of the face which are not opaque
+var aoBrightness = [4]float64{0.5, 0.65, 0.8, 1}
+
+func isOccluder(w *World, p Vec3) bool {
+	_, isOpaque := w.GetBlock(p).(OpaqueBlock)
+	return isOpaque
+}
+
+// faceTangents returns the directions along the two axes of a face facing d
+func faceTangents(d Direction) (Direction, Direction) {
+	switch d {
+	case Up, Down:
+		return Right, Front
+	case Left, Right:
+		return Up, Front
+	default:
+		return Right, Up
+	}
+}
+
+// vertexAmbientOcclusion returns the brightness of the corner at p of the face facing d of the block
+// at position, from the blocks next to the corner in the layer in front of the face
+func vertexAmbientOcclusion(w *World, position Vec3, d Direction, p Point3D) float64 {
+	front := position.Move(d)
+	a, b := faceTangents(d)
+	// move towards the corner along each axis
+	offset := p.Subtract(position.ToPoint3D().Add(Point3D{0.5, 0.5, 0.5}))
+	if DotProduct(offset, a.ToVec3().ToPoint3D()) < 0 {
+		a = a.GetOppositeDirection()
+	}
+	if DotProduct(offset, b.ToVec3().ToPoint3D()) < 0 {
+		b = b.GetOppositeDirection()
+	}
+
+	side1 := isOccluder(w, front.Move(a))
+	side2 := isOccluder(w, front.Move(b))
+	if side1 && side2 {
+		// the corner block cannot be seen
+		return aoBrightness[0]
+	}
+	numOpen := 3
+	for _, occluded := range [...]bool{side1, side2, isOccluder(w, front.Move(a).Move(b))} {
+		if occluded {
+			numOpen--
+		}
+	}
+	return aoBrightness[numOpen]
+}
+
+// triangleAmbientOcclusion returns the brightness of each vertex of a triangle of the face facing d
+// of the block at position
+func triangleAmbientOcclusion(w *World, position Vec3, d Direction, v1, v2, v3 Vertex) [3]float64 {
+	return [3]float64{
+		vertexAmbientOcclusion(w, position, d, v1.Position),
+		vertexAmbientOcclusion(w, position, d, v2.Position),
+		vertexAmbientOcclusion(w, position, d, v3.Position),
+	}
+}
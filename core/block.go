@@ -32,6 +32,19 @@ type WireRenderBlock interface {
 	ToCuboids(scene *Scene) []Cuboid
 }
 
+// ModelBlock is drawn with the block model its blockstates asset selects for its state
+type ModelBlock interface {
+	ModelName() string // name of the blockstates asset
+	// BlockState returns the properties of the block as key=value pairs, sorted by key and
+	// separated by commas, e.g. "facing=up,lit=true"
+	BlockState() string
+}
+
+// LightEmittingBlock emits block light, from 0 to MaxLightLevel
+type LightEmittingBlock interface {
+	LightLevel() int
+}
+
 type OpaqueBlock interface {
 	IsOpaqueInDirection(d Direction) bool
 }
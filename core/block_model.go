@@ -0,0 +1,254 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Block models are JSON assets similar to Minecraft's. The blockstates asset of a block selects a
+// model, and how it is rotated, for each state of the block. A model declares the cuboids the
+// block is drawn with, in 1/16ths of a block, and can take its elements from a parent model.
+
+// modelBlockStates is every state of the blocks drawn with block models. Loading the models
+// fails if one has no variant in its blockstates asset, rather than when the block is drawn.
+var modelBlockStates = func() []ModelBlock {
+	var blocks []ModelBlock
+	for _, d := range [...]Direction{Up, Down, Left, Right, Front, Back} {
+		for _, on := range [...]bool{false, true} {
+			blocks = append(blocks, RedstoneTorch{Direction: d, IsPowered: on}, Lever{Direction: d, IsOn: on})
+		}
+	}
+	return blocks
+}()
+
+// blockStatesJSON maps the state of a block, as returned by ModelBlock.BlockState, to a model
+type blockStatesJSON struct {
+	Variants map[string]blockVariantJSON `json:"variants"`
+}
+
+// blockVariantJSON is a model rotated around the centre of the block, in degrees, around Z then X then Y
+type blockVariantJSON struct {
+	Model string  `json:"model"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Z     float64 `json:"z"`
+}
+
+type blockModelJSON struct {
+	Parent string `json:"parent"`
+	// texture variables used by faces as "#name", merged with and overriding the parent's
+	Textures map[string]string  `json:"textures"`
+	Elements []modelElementJSON `json:"elements"` // the parent's elements if empty
+}
+
+type modelElementJSON struct {
+	From     [3]float64               `json:"from"`
+	To       [3]float64               `json:"to"`
+	Rotation *elementRotationJSON     `json:"rotation"`
+	Color    *[4]uint8                `json:"color"`
+	Faces    map[string]modelFaceJSON `json:"faces"` // keyed by the direction the face faces
+}
+
+type elementRotationJSON struct {
+	Origin [3]float64 `json:"origin"`
+	Axis   string     `json:"axis"`
+	Angle  float64    `json:"angle"`
+}
+
+type modelFaceJSON struct {
+	Texture string     `json:"texture"`
+	UV      [4]float64 `json:"uv"` // x0, y0, x1, y1 in pixels of the texture
+}
+
+type bakedFace struct {
+	texture string
+	uv      [4]float64
+}
+
+// bakedElement is an element of a model, rotated by the element's and the variant's rotations
+type bakedElement struct {
+	vertices [8]Point3D
+	color    color.RGBA
+	faces    [6]bakedFace // in the order of cuboidFaceDirections
+}
+
+// BlockModels stores the baked elements of each state of the blocks with models
+type BlockModels struct {
+	variants map[string]map[string][]bakedElement // by block name then state
+}
+
+func LoadBlockModels() (BlockModels, error) {
+	return loadBlockModels(modelBlockStates, LoadAsset)
+}
+
+// loadBlockModels loads the blockstates asset of each block, which must have a variant for every state of blocks
+func loadBlockModels(blocks []ModelBlock, load func(filename string) ([]byte, error)) (BlockModels, error) {
+	m := BlockModels{variants: make(map[string]map[string][]bakedElement)}
+	models := make(map[string]*blockModelJSON)
+	for _, b := range blocks {
+		name := b.ModelName()
+		if _, isLoaded := m.variants[name]; isLoaded {
+			continue
+		}
+		data, err := load(fmt.Sprintf("blockstates/%s.json", name))
+		if err != nil {
+			return m, fmt.Errorf("failed to load blockstates of %s: %w", name, err)
+		}
+		var states blockStatesJSON
+		if err := json.Unmarshal(data, &states); err != nil {
+			return m, fmt.Errorf("error reading blockstates of %s: %w", name, err)
+		}
+		m.variants[name] = make(map[string][]bakedElement)
+		for state, variant := range states.Variants {
+			elements, textures, err := resolveBlockModel(variant.Model, models, load)
+			if err != nil {
+				return m, fmt.Errorf("%s[%s]: %w", name, state, err)
+			}
+			baked, err := bakeBlockModel(variant, elements, textures)
+			if err != nil {
+				return m, fmt.Errorf("%s[%s]: %w", name, state, err)
+			}
+			m.variants[name][state] = baked
+		}
+	}
+	for _, b := range blocks {
+		if _, hasVariant := m.variants[b.ModelName()][b.BlockState()]; !hasVariant {
+			return m, fmt.Errorf("blockstates of %s have no variant for %s", b.ModelName(), b.BlockState())
+		}
+	}
+	return m, nil
+}
+
+// resolveBlockModel returns the elements of the model and its merged texture variables, following its parents
+func resolveBlockModel(name string, models map[string]*blockModelJSON, load func(filename string) ([]byte, error)) ([]modelElementJSON, map[string]string, error) {
+	textures := make(map[string]string)
+	var elements []modelElementJSON
+	for depth := 0; name != ""; depth++ {
+		if depth > 16 {
+			return nil, nil, fmt.Errorf("model %s has too many parents", name)
+		}
+		model, isLoaded := models[name]
+		if !isLoaded {
+			data, err := load(fmt.Sprintf("models/%s.json", name))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load model %s: %w", name, err)
+			}
+			model = &blockModelJSON{}
+			if err := json.Unmarshal(data, model); err != nil {
+				return nil, nil, fmt.Errorf("error reading model %s: %w", name, err)
+			}
+			models[name] = model
+		}
+		// the child's textures override its parents'
+		for k, v := range model.Textures {
+			if _, isSet := textures[k]; !isSet {
+				textures[k] = v
+			}
+		}
+		if elements == nil {
+			elements = model.Elements
+		}
+		name = model.Parent
+	}
+	return elements, textures, nil
+}
+
+func resolveTexture(texture string, textures map[string]string) (string, error) {
+	for i := 0; strings.HasPrefix(texture, "#"); i++ {
+		name, isSet := textures[texture[1:]]
+		if !isSet || i > len(textures) {
+			return "", fmt.Errorf("texture variable %s is not set", texture)
+		}
+		texture = name
+	}
+	return texture, nil
+}
+
+func rotateAroundAxis(p Point3D, axis string, angle float64) (Point3D, error) {
+	switch axis {
+	case "x":
+		return p.RotateX(angle), nil
+	case "y":
+		return p.RotateY(angle), nil
+	case "z":
+		return p.RotateZ(angle), nil
+	default:
+		return p, fmt.Errorf("unknown rotation axis %q", axis)
+	}
+}
+
+func bakeBlockModel(variant blockVariantJSON, elements []modelElementJSON, textures map[string]string) ([]bakedElement, error) {
+	s := Point3DFromScalar(16)
+	centre := Point3D{8, 8, 8}.Divide(s)
+	baked := make([]bakedElement, len(elements))
+	for i, e := range elements {
+		b := &baked[i]
+		cuboid := MakeAxisAlignedCuboid(
+			Point3D{e.From[0], e.From[1], e.From[2]}.Divide(s),
+			Point3D{e.To[0], e.To[1], e.To[2]}.Divide(s),
+			color.RGBA{255, 255, 255, 255},
+			nil,
+		)
+		b.vertices = cuboid.vertices
+		if e.Color != nil {
+			b.color = color.RGBA{e.Color[0], e.Color[1], e.Color[2], e.Color[3]}
+		} else {
+			b.color = cuboid.Color
+		}
+
+		for v := range b.vertices {
+			p := b.vertices[v]
+			if r := e.Rotation; r != nil {
+				origin := Point3D{r.Origin[0], r.Origin[1], r.Origin[2]}.Divide(s)
+				rotated, err := rotateAroundAxis(p.Subtract(origin), r.Axis, DegToRad(r.Angle))
+				if err != nil {
+					return nil, fmt.Errorf("element %d: %w", i, err)
+				}
+				p = rotated.Add(origin)
+			}
+			if variant.X != 0 || variant.Y != 0 || variant.Z != 0 {
+				p = p.Subtract(centre).
+					RotateZ(DegToRad(variant.Z)).
+					RotateX(DegToRad(variant.X)).
+					RotateY(DegToRad(variant.Y)).
+					Add(centre)
+			}
+			b.vertices[v] = p
+		}
+
+		for f, d := range cuboidFaceDirections {
+			face, hasFace := e.Faces[d.String()]
+			if !hasFace {
+				return nil, fmt.Errorf("element %d has no %s face", i, d)
+			}
+			texture, err := resolveTexture(face.Texture, textures)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			b.faces[f] = bakedFace{texture, face.UV}
+		}
+	}
+	return baked, nil
+}
+
+// Cuboids returns the cuboids of the model selected by the block's state. Every state in
+// modelBlockStates has a model once loaded, so a missing model is a programming error.
+func (m *BlockModels) Cuboids(b ModelBlock, scene *Scene) []Cuboid {
+	elements, hasModel := m.variants[b.ModelName()][b.BlockState()]
+	if !hasModel {
+		panic(fmt.Sprintf("no block model for %s[%s]", b.ModelName(), b.BlockState()))
+	}
+	cuboids := make([]Cuboid, len(elements))
+	for i, e := range elements {
+		uvs := make([][4][2]float64, len(e.faces))
+		for f, face := range e.faces {
+			uv := face.uv
+			x0, y0, x1, y1 := cuboidUVArea(uv[0], uv[1], uv[2]-uv[0], uv[3]-uv[1], face.texture, scene)
+			uvs[f] = cuboidFaceUVs(f, x0, y0, x1, y1)
+		}
+		cuboids[i] = Cuboid{e.vertices, e.color, uvs}
+	}
+	return cuboids
+}
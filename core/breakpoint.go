@@ -0,0 +1,139 @@
+package core
+
+import "fmt"
+
+type BreakpointCondition int
+
+const (
+	// BreakOnChange breaks when a block changes in any way
+	BreakOnChange BreakpointCondition = iota
+	// BreakOnPowered breaks when a block turns on
+	BreakOnPowered
+	// BreakOnUnpowered breaks when a block turns off
+	BreakOnUnpowered
+)
+
+func (c BreakpointCondition) String() string {
+	switch c {
+	case BreakOnChange:
+		return "changes"
+	case BreakOnPowered:
+		return "turns on"
+	case BreakOnUnpowered:
+		return "turns off"
+	default:
+		panic("BreakpointCondition not implemented")
+	}
+}
+
+func (r WorldRegion) Contains(p Vec3) bool {
+	return !p.InRange(r.Min, r.Max)
+}
+
+// Breakpoint pauses the game at the end of any tick in which a block in Region,
+// of BlockType if set, meets the Condition
+type Breakpoint struct {
+	Region    WorldRegion
+	BlockType string
+	Condition BreakpointCondition
+}
+
+// BlockBreakpoint breaks when the block at p changes
+func BlockBreakpoint(p Vec3, condition BreakpointCondition) Breakpoint {
+	return Breakpoint{Region: WorldRegion{p, p.Add(Vec3{1, 1, 1})}, Condition: condition}
+}
+
+func (b Breakpoint) String() string {
+	blockType := b.BlockType
+	if blockType == "" {
+		blockType = "any block"
+	}
+	var where string
+	if b.Region.Max == b.Region.Min.Add(Vec3{1, 1, 1}) {
+		where = fmt.Sprintf("at %v", b.Region.Min)
+	} else {
+		where = fmt.Sprintf("in %v-%v", b.Region.Min, b.Region.Max)
+	}
+	return fmt.Sprintf("%s %s %s", blockType, where, b.Condition)
+}
+
+// isBlockOn returns whether the block is on, and false if the block cannot be on
+func isBlockOn(b Block) (bool, bool) {
+	switch b := b.(type) {
+	case Lever:
+		return b.IsOn, true
+	case RedstoneTorch:
+		return b.IsPowered, true
+	case RedstoneLamp:
+		return b.isPowered(), true
+	case WoolBlock:
+		return b.InputPowerType != None, true
+	case RedstoneBlock:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func (b Breakpoint) matches(before Block, after Block) bool {
+	if before == after {
+		return false
+	}
+	if b.BlockType != "" && (before == nil || before.Type() != b.BlockType) && (after == nil || after.Type() != b.BlockType) {
+		return false
+	}
+	switch b.Condition {
+	case BreakOnPowered, BreakOnUnpowered:
+		wasOn, _ := isBlockOn(before)
+		isOn, canBeOn := isBlockOn(after)
+		return canBeOn && wasOn != isOn && isOn == (b.Condition == BreakOnPowered)
+	default:
+		return true
+	}
+}
+
+type BreakpointHit struct {
+	Breakpoint Breakpoint
+	Iteration  int
+	Position   Vec3
+	Before     Block
+	After      Block
+}
+
+func (h BreakpointHit) String() string {
+	return fmt.Sprintf("I: %d, %v, hit at %v", h.Iteration, h.Breakpoint, h.Position)
+}
+
+// ToggleBreakpoint adds a breakpoint, or removes it if an equal breakpoint already exists.
+// Returns true if the breakpoint was added.
+func (scene *Scene) ToggleBreakpoint(b Breakpoint) bool {
+	for i, existing := range scene.Breakpoints {
+		if existing == b {
+			scene.Breakpoints = append(scene.Breakpoints[:i], scene.Breakpoints[i+1:]...)
+			return false
+		}
+	}
+	scene.Breakpoints = append(scene.Breakpoints, b)
+	return true
+}
+
+// checkBreakpoints pauses the game if the changes since before meet any breakpoint
+func (scene *Scene) checkBreakpoints(before *[WorldSize]Block, iteration int) {
+	w := &scene.World
+	for _, b := range scene.Breakpoints {
+		for z := max(0, b.Region.Min.Z); z < min(b.Region.Max.Z, WorldDepth); z++ {
+			for y := max(0, b.Region.Min.Y); y < min(b.Region.Max.Y, WorldHeight); y++ {
+				for x := max(0, b.Region.Min.X); x < min(b.Region.Max.X, WorldWidth); x++ {
+					p := Vec3{X: x, Y: y, Z: z}
+					i := w.GetIndex(p)
+					if b.matches(before[i], w.Blocks[i]) {
+						scene.BreakpointHit = &BreakpointHit{b, iteration, p, before[i], w.Blocks[i]}
+						scene.GameState = Paused
+						fmt.Println("Breakpoint hit:", scene.BreakpointHit)
+						return
+					}
+				}
+			}
+		}
+	}
+}
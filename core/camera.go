@@ -17,11 +17,7 @@ type Camera struct {
 // Convert3DTo2D projects a 3D point onto a 2D plane using perspective projection, considering the camera's position and orientation
 func (c *Camera) Convert3DTo2D(point Point3D) *Point2D {
 	// Translate the point by the camera position
-	p := point.
-		Subtract(c.Position).
-		RotateY(c.Rotation.Y).
-		RotateX(c.Rotation.X).
-		RotateZ(c.Rotation.Z)
+	p := c.toView(point)
 
 	// Check if the point is outside the viewable range
 	if p.Z <= c.Near || p.Z >= c.Far {
@@ -63,3 +59,83 @@ func Convert3DTo2D(point Point3D, fov, aspectRatio, near, far float64) Point2D {
 
 	return Point2D{X: screenX, Y: screenY}
 }
+
+// toView transforms a point to view space, where the camera looks along +Z
+func (c *Camera) toView(point Point3D) Point3D {
+	return point.
+		Subtract(c.Position).
+		RotateY(c.Rotation.Y).
+		RotateX(c.Rotation.X).
+		RotateZ(c.Rotation.Z)
+}
+
+// Frustum is the volume the camera can see
+type Frustum struct {
+	camera Camera
+	fovRad float64 // projection scale of view space x and y
+	q      float64 // projection scale of view space z
+}
+
+func (c *Camera) Frustum() Frustum {
+	return Frustum{
+		camera: *c,
+		fovRad: 1.0 / math.Tan(c.FOV*0.5*math.Pi/180),
+		q:      c.Far / (c.Far - c.Near),
+	}
+}
+
+const numFrustumPlanes = 6
+
+// planeDistance returns the signed distance of the view space point p from a plane of
+// the frustum (near, far, left, right, bottom, top), scaled by the projection. The point
+// is inside the plane if the distance is not negative.
+func (f Frustum) planeDistance(plane int, p Point3D) float64 {
+	switch plane {
+	case 0:
+		return p.Z - f.camera.Near
+	case 1:
+		return f.camera.Far - p.Z
+	case 2:
+		return p.Z*f.q + p.X*f.fovRad*f.camera.AspectRatio
+	case 3:
+		return p.Z*f.q - p.X*f.fovRad*f.camera.AspectRatio
+	case 4:
+		return p.Z*f.q + p.Y*f.fovRad
+	case 5:
+		return p.Z*f.q - p.Y*f.fovRad
+	default:
+		panic("frustum plane out of range")
+	}
+}
+
+// IntersectsBox returns false if the box is entirely outside one of the planes of the frustum,
+// so every triangle inside the box would be clipped away
+func (f Frustum) IntersectsBox(min, max Point3D) bool {
+	var corners [8]Point3D
+	for i := range corners {
+		corner := min
+		if i&1 != 0 {
+			corner.X = max.X
+		}
+		if i&2 != 0 {
+			corner.Y = max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = max.Z
+		}
+		corners[i] = f.camera.toView(corner)
+	}
+	for plane := 0; plane < numFrustumPlanes; plane++ {
+		isOutside := true
+		for _, p := range corners {
+			if f.planeDistance(plane, p) >= 0 {
+				isOutside = false
+				break
+			}
+		}
+		if isOutside {
+			return false
+		}
+	}
+	return true
+}
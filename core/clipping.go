@@ -0,0 +1,90 @@
+package core
+
+// each plane can add at most one vertex to a convex polygon
+const maxClipVertices = 3 + numFrustumPlanes
+
+// clipVertex is a vertex in view space
+type clipVertex struct {
+	p    Point3D
+	u, v float64
+	ao   float64
+}
+
+func lerpClipVertex(a, b clipVertex, t float64) clipVertex {
+	return clipVertex{
+		p: Point3D{
+			X: a.p.X + t*(b.p.X-a.p.X),
+			Y: a.p.Y + t*(b.p.Y-a.p.Y),
+			Z: a.p.Z + t*(b.p.Z-a.p.Z),
+		},
+		u:  a.u + t*(b.u-a.u),
+		v:  a.v + t*(b.v-a.v),
+		ao: a.ao + t*(b.ao-a.ao),
+	}
+}
+
+// clipPolygon clips the convex polygon to the inside of a plane of the frustum using
+// Sutherland-Hodgman, appending the vertices to out. A polygon entirely inside is unchanged.
+func (f Frustum) clipPolygon(plane int, polygon []clipVertex, out []clipVertex) []clipVertex {
+	if len(polygon) == 0 {
+		return out
+	}
+	prev := polygon[len(polygon)-1]
+	prevDistance := f.planeDistance(plane, prev.p)
+	for _, cur := range polygon {
+		distance := f.planeDistance(plane, cur.p)
+		if (distance >= 0) != (prevDistance >= 0) {
+			// the edge crosses the plane
+			t := prevDistance / (prevDistance - distance)
+			out = append(out, lerpClipVertex(prev, cur, t))
+		}
+		if distance >= 0 {
+			out = append(out, cur)
+		}
+		prev, prevDistance = cur, distance
+	}
+	return out
+}
+
+// clipTriangle clips the triangle, with the ambient occlusion of each vertex, to the frustum,
+// the vertices of the clipped polygon are written to out and returned
+func (f Frustum) clipTriangle(v1, v2, v3 Vertex, ao [3]float64, out *[maxClipVertices]clipVertex) []clipVertex {
+	var buffer [maxClipVertices]clipVertex
+	polygon := append(out[:0],
+		clipVertex{f.camera.toView(v1.Position), v1.U, v1.V, ao[0]},
+		clipVertex{f.camera.toView(v2.Position), v2.U, v2.V, ao[1]},
+		clipVertex{f.camera.toView(v3.Position), v3.U, v3.V, ao[2]},
+	)
+	for plane := 0; plane < numFrustumPlanes; plane++ {
+		// clip between the two buffers, so the result ends up in out
+		in, next := polygon, buffer[:0]
+		if plane%2 == 1 {
+			next = out[:0]
+		}
+		polygon = f.clipPolygon(plane, in, next)
+	}
+	return polygon
+}
+
+// project converts a clipped vertex to image coordinates
+func (f Frustum) project(c clipVertex, imageSize Point2D) ImgPoint {
+	ndcX := c.p.X * f.fovRad * f.camera.AspectRatio
+	ndcY := c.p.Y * f.fovRad
+	ndcZ := c.p.Z * f.q
+	screenX := ndcX / ndcZ
+	screenY := ndcY / ndcZ
+
+	halfWidth, halfHeight := imageSize.X/2, imageSize.Y/2
+	w := 1.0
+	if TexturePerspective {
+		w = ndcZ
+	}
+	return ImgPoint{
+		int(screenX*halfWidth + halfWidth),
+		int(-screenY*halfHeight + halfHeight),
+		ndcZ,
+		c.u / w,
+		c.v / w,
+		c.ao / w,
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"image"
 	"math"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -81,3 +82,55 @@ func BenchmarkSins(b *testing.B) {
 	benchmarkSinFunc(b, FastSin, "FastSin")
 
 }
+
+// createIdleWorld fills half the world with unpowered wool and a single lever powered lamp
+func createIdleWorld(world *World) Vec3 {
+	for x := 0; x < WorldWidth; x++ {
+		for y := 0; y < WorldHeight/2; y++ {
+			for z := 0; z < WorldDepth; z++ {
+				world.SetBlock(Vec3{X: x, Y: y, Z: z}, WoolBlock{Color((x + z) % 16), None})
+			}
+		}
+	}
+	lamp := Vec3{X: 8, Y: WorldHeight / 2, Z: 8}
+	world.SetBlock(lamp, RedstoneLamp{InputPowerType: None})
+	lever := lamp.Move(Up)
+	world.SetBlock(lever, Lever{Direction: Up, IsOn: false})
+	return lever
+}
+
+func BenchmarkIdleWorldUpdate(b *testing.B) {
+	for _, full := range []bool{true, false} {
+		name := "ActiveSet"
+		if full {
+			name = "FullSweep"
+		}
+		b.Run(name, func(b *testing.B) {
+			world := World{}
+			lever := createIdleWorld(&world)
+			stepTestWorld(&world, full)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				toggleLever(lever, &world)
+				stepTestWorld(&world, full)
+			}
+		})
+	}
+}
+
+func BenchmarkBusyWorldUpdateWorkers(b *testing.B) {
+	for workers := 1; workers <= max(8, runtime.NumCPU()); workers *= 2 {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			world := World{}
+			levers := createBusyWorld(&world)
+			stepTestWorldWithWorkers(&world, workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, lever := range levers {
+					toggleLever(lever, &world)
+				}
+				stepTestWorldWithWorkers(&world, workers)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"image"
+	"runtime"
 	"time"
 
 	"golang.org/x/image/font"
@@ -19,8 +20,38 @@ type Scene struct {
 	Camera    Camera
 	World     World
 	Player    Player
+	// simulation
+	SimulationMode    SimulationMode
+	Scheduler         TickScheduler
+	SimulationWorkers int // goroutines used to update the world, 1 is serial
+	// set when the last step's sub updates did not settle
+	SubUpdateOscillation *SubUpdateOscillation
+	PauseOnOscillation   bool
+	PeriodDetector       PeriodDetector
+	InputLog             InputLog
+	History              History
+	Breakpoints          []Breakpoint
+	BreakpointHit        *BreakpointHit    // the breakpoint that paused the last step
+	breakpointBlocks     *[WorldSize]Block // the world before the step, reused between steps
+	// rendering
+	RenderWorkers  int // goroutines used to rasterize the world, 1 is serial
+	rasterizer     TileRasterizer
+	worldChanges   worldChanges // blocks changed since the last frame, for the mesh cache and light map
+	meshCache      MeshCache
+	translucent    translucentPass
+	Culling        CullingStats // blocks drawn and culled in the last frame
+	LightMap       LightMap
+	LightModel     LightModel
+	TextureSampler TextureSampler
+	BlockModels    BlockModels
+	// speed controls
+	SpeedMultiplier float64 // steps per update event, below 1 is slow motion
+	MaxSpeed        bool    // step for as long as the update event's time budget allows
+	StepsToRun      int     // steps left while Pausing
+	stepAccumulator float64
+	numStepsRun     int // steps since the statistics were last recorded
 	// metrics
-	FramesPerSecond                   int // not being used anymore to set frame rate along with other vars
+	FramesPerSecond                   int // target rates, can be changed while the game loop runs
 	StepsPerSecond                    int
 	SubStepsPerSecond                 int
 	NumBlockUpdatesInStep             int
@@ -28,23 +59,39 @@ type Scene struct {
 	NumBlockSubUpdatesInStep          int
 	RecordedFramesPerSecond           int
 	RecordedStepsPerSecond            int
+	EventTimings                      map[string]*TimingHistory // from the game loop, by event name
+	ShowFrameTimeGraph                bool
 
 	FontFace font.Face // should be a store of multiple fonts or internal handler for loaded assets
 	Tilemap  Tilemap
 }
 
 func ratePerSecondToDuration(rate int) time.Duration {
-	return time.Duration(1.0/float64(rate)*1000.0) * time.Millisecond
+	return time.Second / time.Duration(max(rate, 1))
 }
 
-func RunGameSave(scene *Scene) {
-	period := ratePerSecondToDuration(1)
-	// scene.Iteration = 0
-	for scene.GameState != Quit {
+var ratesPerSecond = [...]int{1, 2, 5, 10, 20, 30, 60, 120}
+
+// changeRate moves a rate up or down the ladder of rates per second
+func changeRate(rate int, faster bool) int {
+	i := 0
+	for i < len(ratesPerSecond)-1 && ratesPerSecond[i] < rate {
+		i++
+	}
+	if faster {
+		i = min(i+1, len(ratesPerSecond)-1)
+	} else {
+		i = max(i-1, 0)
+	}
+	return ratesPerSecond[i]
+}
+
+// CreateGameSaveEvent creates an event which saves the camera once per second
+func CreateGameSaveEvent(scene *Scene) GameEvent {
+	return CreateGameEvent("GameSave", ratePerSecondToDuration(1), func(event *GameEvent, gameLoopManager *GameLoopManager) {
 		gameSave := GameSave{CameraPosition: scene.Camera.Position, CameraRotation: scene.Camera.Rotation}
 		WriteGameSame(gameSave)
-		time.Sleep(period)
-	}
+	})
 }
 
 type SceneEvent interface {
@@ -70,6 +117,11 @@ func InitialiseScene(scene *Scene, sceneImage *image.RGBA, scale int) {
 	scene.FramesPerSecond = 2
 	scene.StepsPerSecond = 2
 	scene.SubStepsPerSecond = 0
+	scene.SpeedMultiplier = 1
+	scene.SimulationWorkers = runtime.NumCPU()
+	scene.RenderWorkers = runtime.NumCPU()
+	scene.LightModel = DefaultLightModel()
+	scene.TextureSampler = TextureSampler{Filter: NearestFilter, Mipmaps: true}
 
 	width := sceneImage.Bounds().Dx()
 	height := sceneImage.Bounds().Dy()
@@ -102,4 +154,10 @@ func InitialiseScene(scene *Scene, sceneImage *image.RGBA, scale int) {
 	SaveImage(&tilemap.Image, "output/tilemap.png")
 	// fmt.Println(tilemap.Metas)
 	scene.Tilemap = *tilemap
+
+	blockModels, err := LoadBlockModels()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load block models: %v", err))
+	}
+	scene.BlockModels = blockModels
 }
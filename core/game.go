@@ -24,3 +24,23 @@ func (g GameState) String() string {
 		panic("GameState not implemented")
 	}
 }
+
+type SimulationMode int
+
+const (
+	// CellularAutomata updates every block simultaneously each step
+	CellularAutomata SimulationMode = iota
+	// ScheduledTicks updates blocks in order using minecraft's tick scheduler
+	ScheduledTicks
+)
+
+func (m SimulationMode) String() string {
+	switch m {
+	case CellularAutomata:
+		return "CA"
+	case ScheduledTicks:
+		return "Ticks"
+	default:
+		panic("SimulationMode not implemented")
+	}
+}
@@ -198,35 +198,42 @@ func Update(scene *Scene) {
 	if scene.GameState != Playing && scene.GameState != Pausing {
 		return
 	}
-	maxSubUpdateIterations := 50
 	// startTime := NowInSeconds()
-
-	numUpdates := 0
-	// Process User Inputs
-	if ProcessUserInputs(scene.Iteration, &scene.World) {
-		numUpdates += 1
+	scene.History.Begin(scene)
+	scene.BreakpointHit = nil
+	var before *[WorldSize]Block
+	if len(scene.Breakpoints) > 0 {
+		if scene.breakpointBlocks == nil {
+			scene.breakpointBlocks = new([WorldSize]Block)
+		}
+		before = scene.breakpointBlocks
+		*before = scene.World.Blocks
 	}
-	// Process Sub Updates
-	totalSubUpdates := 0
-	i := 0
-	for i < maxSubUpdateIterations {
-		numSubUpdates := scene.World.SubUpdateWorld()
-		totalSubUpdates += numSubUpdates
-		if numSubUpdates == 0 {
-			break
+	iteration := scene.Iteration
+
+	// stepping while rewound replays the recorded tick, only changing the world branches the history
+	if scene.History.Forward(scene, 1) == 0 {
+		// Process User Inputs
+		numInputUpdates := 0
+		if ProcessUserInputs(scene) {
+			numInputUpdates += 1
 		}
-		i++
+		StepSimulation(scene)
+		scene.NumBlockUpdatesInStep += numInputUpdates
+		scene.History.Record(scene)
+	}
+	scene.numStepsRun++
+	if before != nil {
+		scene.checkBreakpoints(before, iteration)
 	}
-	scene.NumBlockSubUpdateIterationsInStep = i
-	scene.NumBlockSubUpdatesInStep = totalSubUpdates
-
-	// Process Updates
-	numUpdates += scene.World.UpdateWorld()
 
-	scene.NumBlockUpdatesInStep = numUpdates
-	scene.Iteration = scene.Iteration + 1
 	if scene.GameState == Pausing {
-		scene.GameState = Paused
+		if scene.StepsToRun > 1 {
+			scene.StepsToRun--
+		} else {
+			scene.StepsToRun = 0
+			scene.GameState = Paused
+		}
 	}
 	// elapsedTime := NowInSeconds() - startTime
 	// if elapsedTime < (1.0 / 10_000.0) {
@@ -236,6 +243,100 @@ func Update(scene *Scene) {
 	// }
 }
 
+var speedMultipliers = [...]float64{0.125, 0.25, 0.5, 1, 2, 4, 8, 16}
+
+// ChangeSpeed moves the speed multiplier up or down the ladder of speeds
+func ChangeSpeed(scene *Scene, faster bool) {
+	i := 0
+	for i < len(speedMultipliers)-1 && speedMultipliers[i] < scene.SpeedMultiplier {
+		i++
+	}
+	if faster {
+		i = min(i+1, len(speedMultipliers)-1)
+	} else {
+		i = max(i-1, 0)
+	}
+	scene.SpeedMultiplier = speedMultipliers[i]
+	scene.stepAccumulator = 0
+}
+
+// RunUpdateEvent runs the steps due on an update event of period nanoseconds, applying the
+// speed controls. Returns the number of steps run.
+func RunUpdateEvent(scene *Scene, period int64) int {
+	isRunning := func() bool {
+		return scene.GameState == Playing || scene.GameState == Pausing
+	}
+	numSteps := 0
+	if scene.MaxSpeed {
+		// leave half of the period for rendering
+		deadline := nanotime() + period/2
+		for isRunning() && (numSteps == 0 || nanotime() < deadline) {
+			Update(scene)
+			numSteps++
+		}
+		return numSteps
+	}
+
+	if !isRunning() {
+		return 0
+	}
+	scene.stepAccumulator += scene.SpeedMultiplier
+	for scene.stepAccumulator >= 1 && isRunning() {
+		scene.stepAccumulator--
+		Update(scene)
+		numSteps++
+	}
+	return numSteps
+}
+
+// SpeedString describes the current speed controls for the HUD
+func (scene *Scene) SpeedString() string {
+	var speed string
+	if scene.MaxSpeed {
+		speed = "max"
+	} else {
+		speed = fmt.Sprintf("x%g (%g/s)", scene.SpeedMultiplier, float64(scene.StepsPerSecond)*scene.SpeedMultiplier)
+	}
+	if scene.GameState == Pausing {
+		speed += fmt.Sprintf(", %d steps left", scene.StepsToRun)
+	}
+	return speed
+}
+
+// StepSimulation advances the world by one step, without any user input.
+// Used directly to run the simulation headless.
+func StepSimulation(scene *Scene) {
+	maxSubUpdateIterations := 50
+
+	numUpdates := 0
+	if scene.SimulationMode == ScheduledTicks {
+		scheduler := &scene.Scheduler
+		scheduler.UpdateWorld(&scene.World)
+		scene.NumBlockSubUpdateIterationsInStep = scheduler.NumScheduledTicks
+		scene.NumBlockSubUpdatesInStep = scheduler.NumNeighbourUpdates
+		numUpdates += scheduler.NumBlockChanges
+	} else {
+		// Process Sub Updates
+		i, totalSubUpdates, oscillation := scene.World.SubUpdateWorldUntilStable(maxSubUpdateIterations, scene.SimulationWorkers)
+		scene.NumBlockSubUpdateIterationsInStep = i
+		scene.NumBlockSubUpdatesInStep = totalSubUpdates
+		if oscillation != nil {
+			oscillation.Iteration = scene.Iteration
+			if scene.PauseOnOscillation {
+				scene.GameState = Paused
+			}
+		}
+		scene.SubUpdateOscillation = oscillation
+
+		// Process Updates
+		numUpdates += scene.World.ParallelUpdateWorld(scene.SimulationWorkers)
+	}
+
+	scene.NumBlockUpdatesInStep = numUpdates
+	scene.PeriodDetector.Record(scene.Iteration, &scene.World)
+	scene.Iteration = scene.Iteration + 1
+}
+
 func RunEngine2(sceneImage *image.RGBA, scale int) {
 
 	sleepUndershoot := 10 * time.Millisecond
@@ -252,7 +353,7 @@ func RunEngine2(sceneImage *image.RGBA, scale int) {
 
 	update := func(event *GameEvent, gameLoopManager *GameLoopManager) {
 		// keyboardManager.Update()
-		Update(&scene)
+		RunUpdateEvent(&scene, event.Period)
 		if scene.GameState == Quit {
 			close(quit)
 		}
@@ -267,22 +368,27 @@ func RunEngine2(sceneImage *image.RGBA, scale int) {
 	}
 
 	updateStatistics := func(event *GameEvent, gameLoopManager *GameLoopManager) {
-		renderEvent := &gameLoopManager.Events[1]
+		renderEvent := gameLoopManager.GetEvent("Render")
 		scene.RecordedFramesPerSecond = renderEvent.CallCount
 		renderEvent.CallCount = 0
 
-		updateEvent := &gameLoopManager.Events[0]
-		scene.RecordedStepsPerSecond = updateEvent.CallCount
+		updateEvent := gameLoopManager.GetEvent("Update")
+		scene.RecordedStepsPerSecond = scene.numStepsRun
+		scene.numStepsRun = 0
 		updateEvent.CallCount = 0
 	}
 
-	events := [3]GameEvent{
-		CreateGameEvent("Update", (1000/2)*time.Millisecond, update),
-		CreateGameEvent("Render", (1_000_000/2)*time.Microsecond, render),
+	stepsPerSecond := func() int { return scene.StepsPerSecond }
+	framesPerSecond := func() int { return scene.FramesPerSecond }
+	events := []GameEvent{
+		CreateRateGameEvent("Update", stepsPerSecond, update),
+		CreateRateGameEvent("Render", framesPerSecond, render),
 		CreateGameEvent("Statistics", (1000/1)*time.Millisecond, updateStatistics),
 	}
 
 	g := GameLoopManager{}
+	// the timings of events added later are shown and exported too
+	g.OnEventsChanged = func(g *GameLoopManager) { scene.EventTimings = g.TimingHistories() }
 	g.Initialise(events, sleepUndershoot, quit)
 
 	InitialiseScene(&scene, sceneImage, scale)
@@ -1,18 +1,37 @@
 package core
 
 import (
+	"fmt"
 	"time"
 )
 
 type GameEvent struct {
 	Name             string
-	Period           int64 // nanoseconds
+	Period           int64                // nanoseconds
+	PeriodFunc       func() time.Duration // if set, read every iteration so the period can change at runtime
 	Callback         func(event *GameEvent, gameLoopManager *GameLoopManager)
 	LastCallTime     int64
 	Delta            int64
 	Overshoot        int64
 	CallCount        int
 	ElapsedOvershoot int64
+	Timings          *TimingHistory // durations and start jitter of recent calls
+}
+
+// Clock is the source of time for the game loop, replaced in tests to control time
+type Clock interface {
+	Now() int64 // nanoseconds
+	Sleep(d time.Duration)
+}
+
+type runtimeClock struct{}
+
+func (c runtimeClock) Now() int64 {
+	return nanotime()
+}
+
+func (c runtimeClock) Sleep(d time.Duration) {
+	time.Sleep(d)
 }
 
 type GameLoopManager struct {
@@ -23,9 +42,13 @@ type GameLoopManager struct {
 	ElapsedSleepDuration     int64
 	ElapsedTimeTillNextEvent int64
 	// inputs
-	Events          [3]GameEvent
+	Events          []GameEvent // add, remove and find events by name with AddEvent, RemoveEvent and GetEvent
 	SleepUndershoot int64
 	Quit            chan struct{}
+	Clock           Clock // defaults to the runtime clock
+	// called on the loop's goroutine after an event is added or removed, such as to refresh TimingHistories
+	OnEventsChanged func(g *GameLoopManager)
+	isRunning       bool
 }
 
 func CreateGameEvent(name string, period time.Duration, callback func(event *GameEvent, gameLoopManager *GameLoopManager)) GameEvent {
@@ -33,25 +56,111 @@ func CreateGameEvent(name string, period time.Duration, callback func(event *Gam
 		Name:     name,
 		Period:   period.Nanoseconds(),
 		Callback: callback,
+		Timings:  &TimingHistory{},
 	}
 }
 
+// CreateRateGameEvent creates an event called rate times per second, where rate can change at runtime
+func CreateRateGameEvent(name string, rate func() int, callback func(event *GameEvent, gameLoopManager *GameLoopManager)) GameEvent {
+	periodFunc := func() time.Duration {
+		return ratePerSecondToDuration(rate())
+	}
+	event := CreateGameEvent(name, periodFunc(), callback)
+	event.PeriodFunc = periodFunc
+	return event
+}
+
 func (g *GameLoopManager) Initialise(
-	events [3]GameEvent,
+	events []GameEvent,
 	sleepUndershoot time.Duration,
 	quit chan struct{},
 ) {
-	g.Events = events
+	g.Events = nil
+	for _, event := range events {
+		g.AddEvent(event)
+	}
 	g.SleepUndershoot = sleepUndershoot.Nanoseconds()
 	g.Quit = quit
 }
 
-func (g *GameLoopManager) Run() {
+// AddEvent adds an event to the loop. Events are not locked, so while the loop is running
+// it must only be called from the loop's goroutine, such as from an event's callback.
+// Event names must be unique.
+func (g *GameLoopManager) AddEvent(event GameEvent) {
+	if g.GetEvent(event.Name) != nil {
+		panic(fmt.Sprintf("game event %s already exists", event.Name))
+	}
+	if event.Timings == nil {
+		event.Timings = &TimingHistory{}
+	}
+	if g.isRunning {
+		// we want new events to update immediately
+		event.LastCallTime = g.clock().Now() - event.Period
+	}
+	g.Events = append(g.Events, event)
+	g.eventsChanged()
+}
+
+func (g *GameLoopManager) eventsChanged() {
+	if g.OnEventsChanged != nil {
+		g.OnEventsChanged(g)
+	}
+}
+
+// RemoveEvent removes the event with the name, returns false if there is no such event.
+// Like AddEvent, while the loop is running it must only be called from the loop's goroutine.
+func (g *GameLoopManager) RemoveEvent(name string) bool {
+	for i := range g.Events {
+		if g.Events[i].Name == name {
+			g.Events = append(g.Events[:i], g.Events[i+1:]...)
+			g.eventsChanged()
+			return true
+		}
+	}
+	return false
+}
+
+// GetEvent returns the event with the name, or nil if there is no such event.
+// The pointer is invalidated by adding or removing events.
+func (g *GameLoopManager) GetEvent(name string) *GameEvent {
+	for i := range g.Events {
+		if g.Events[i].Name == name {
+			return &g.Events[i]
+		}
+	}
+	return nil
+}
+
+// TimingHistories returns the timing history of each event by name, the histories
+// stay valid when events are added or removed
+func (g *GameLoopManager) TimingHistories() map[string]*TimingHistory {
+	histories := make(map[string]*TimingHistory, len(g.Events))
+	for i := range g.Events {
+		histories[g.Events[i].Name] = g.Events[i].Timings
+	}
+	return histories
+}
+
+func (g *GameLoopManager) clock() Clock {
+	if g.Clock == nil {
+		return runtimeClock{}
+	}
+	return g.Clock
+}
+
+// Start prepares the events to be called by Iterate, Run calls it
+func (g *GameLoopManager) Start() {
 	// we want all events to update immediately on first call
-	now := nanotime()
+	now := g.clock().Now()
 	for i := range g.Events {
 		g.Events[i].LastCallTime = now - g.Events[i].Period
 	}
+	g.isRunning = true
+}
+
+func (g *GameLoopManager) Run() {
+	g.Start()
+	defer func() { g.isRunning = false }()
 
 	for {
 		select {
@@ -64,12 +173,15 @@ func (g *GameLoopManager) Run() {
 }
 
 func (g *GameLoopManager) Iterate() {
-	now := nanotime()
+	now := g.clock().Now()
 
 	var maxOvershoot int64 = -1
 	var maxOvershootIndex int = -1
 
 	for i := range g.Events {
+		if g.Events[i].PeriodFunc != nil {
+			g.Events[i].Period = g.Events[i].PeriodFunc().Nanoseconds()
+		}
 		delta := now - g.Events[i].LastCallTime
 		overshoot := delta - g.Events[i].Period
 		g.Events[i].Delta = delta
@@ -91,8 +203,16 @@ func (g *GameLoopManager) Iterate() {
 		event.LastCallTime = now - clampedOvershoot
 		event.CallCount++
 		event.ElapsedOvershoot += clampedOvershoot
+		// the callback may add or remove events, so keep the history rather than the event
+		timings := event.Timings
+		jitter := event.Overshoot
 		event.Callback(event, g)
+		timings.Add(g.clock().Now()-now, jitter)
 
+	} else if len(g.Events) == 0 {
+		// nothing to wait for, sleep until the loop is quit
+		g.NoSleepCount++
+		g.clock().Sleep(time.Duration(max(g.SleepUndershoot, time.Millisecond.Nanoseconds())))
 	} else { // sleep
 		var minTimeTillNextEvent int64 = 1e12 // overshoot will be negative
 		for i := range g.Events {
@@ -107,7 +227,7 @@ func (g *GameLoopManager) Iterate() {
 		if sleepDuration > 0 {
 			g.SleepCount++
 			g.ElapsedSleepDuration += sleepDuration
-			time.Sleep(time.Duration(sleepDuration))
+			g.clock().Sleep(time.Duration(sleepDuration))
 		} else {
 			g.NoSleepCount++
 		}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"slices"
 	"testing"
 	"time"
 )
@@ -75,44 +76,313 @@ func NanoToMilliSeconds(nano int64) float64 {
 	return float64(nano) / 1e6
 }
 
+// fakeClock only moves when the game loop sleeps, or when a test advances it
+type fakeClock struct {
+	now    int64
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() int64 {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now += d.Nanoseconds()
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now += d.Nanoseconds()
+}
+
+type gameEventCall struct {
+	name string
+	time time.Duration
+}
+
+// createLoggedGameEvent creates an event which appends each call to calls, and takes cost to run
+func createLoggedGameEvent(name string, period time.Duration, cost time.Duration, clock *fakeClock, calls *[]gameEventCall) GameEvent {
+	return CreateGameEvent(name, period, func(event *GameEvent, gameLoopManager *GameLoopManager) {
+		*calls = append(*calls, gameEventCall{name, time.Duration(clock.Now())})
+		clock.Advance(cost)
+	})
+}
+
 func TestRunGameLoopManager(t *testing.T) {
-	sleepDuration := 1000 * time.Millisecond
-	sleepUndershoot := 10 * time.Millisecond
 	quit := make(chan struct{})
+	clock := &fakeClock{}
+	noop := func(event *GameEvent, gameLoopManager *GameLoopManager) {}
 
-	g := GameLoopManager{}
+	g := GameLoopManager{Clock: clock}
 
-	events := [3]GameEvent{
-		CreateGameEvent("Update", (1000/5)*time.Millisecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {}),       // update
-		CreateGameEvent("Render", (1_000_000/60)*time.Microsecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {}), // render
-		CreateGameEvent("Statistics", (1000/1)*time.Millisecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {}),   // statistics
+	events := []GameEvent{
+		CreateGameEvent("Update", (1000/5)*time.Millisecond, noop),
+		CreateGameEvent("Render", (1_000_000/60)*time.Microsecond, noop),
+		CreateGameEvent("Statistics", (1000/1)*time.Millisecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {
+			// quit after 1 second
+			if event.CallCount == 2 {
+				close(quit)
+			}
+		}),
 	}
 
-	g.Initialise(events, sleepUndershoot, quit)
+	g.Initialise(events, 0, quit)
+	g.Run()
 
-	go func() {
-		time.Sleep(sleepDuration)
-		close(quit)
-	}()
+	if time.Duration(clock.Now()) != time.Second {
+		t.Errorf("Expected the game loop to run for 1 second, but it ran for %v", time.Duration(clock.Now()))
+	}
+	// events due at the same time are called in the order they were added
+	for name, expected := range map[string]int{"Update": 6, "Render": 61, "Statistics": 2} {
+		event := g.GetEvent(name)
+		if event.CallCount != expected {
+			t.Errorf("Expected %s to be called %d times, got %d", name, expected, event.CallCount)
+		}
+		if event.ElapsedOvershoot != 0 {
+			t.Errorf("Expected %s to never overshoot, got %v", name, time.Duration(event.ElapsedOvershoot))
+		}
+	}
+	if g.NoSleepCount != 0 || g.SleepCount+g.GetEvent("Update").CallCount+g.GetEvent("Render").CallCount+2 != g.Iterations {
+		t.Errorf("Expected every iteration to call an event or sleep, got %d iterations, %d sleeps and %d no sleeps",
+			g.Iterations, g.SleepCount, g.NoSleepCount)
+	}
+}
 
-	startTime := time.Now()
-	g.Run()
-	elapsed := time.Since(startTime)
+func TestGameLoopManagerEventOrder(t *testing.T) {
+	clock := &fakeClock{}
+	var calls []gameEventCall
+	g := GameLoopManager{Clock: clock}
+	g.Initialise([]GameEvent{
+		createLoggedGameEvent("A", 100*time.Millisecond, 0, clock, &calls),
+		createLoggedGameEvent("B", 30*time.Millisecond, 0, clock, &calls),
+	}, 0, nil)
 
-	fmt.Println("Iterations\t\t", g.Iterations)
-	fmt.Println("SleepCount\t\t", g.SleepCount)
-	fmt.Println("NoSleepCount\t\t", g.NoSleepCount)
-	fmt.Println("AVG SleepDuration\t", time.Duration(g.ElapsedSleepDuration/int64(g.SleepCount)))
-	fmt.Println("AVG TimeTillNextEvent\t", time.Duration(g.ElapsedTimeTillNextEvent/int64(g.SleepCount+g.NoSleepCount)))
+	g.Start()
+	for len(calls) < 10 {
+		g.Iterate()
+	}
 
-	for _, event := range g.Events {
-		fmt.Println(event.Name)
-		fmt.Println("  CallCount\t\t", event.CallCount)
-		fmt.Println("  AVG Overshoot\t\t", time.Duration(event.ElapsedOvershoot/int64(event.CallCount)))
+	ms := time.Millisecond
+	expected := []gameEventCall{
+		{"A", 0}, {"B", 0}, {"B", 30 * ms}, {"B", 60 * ms}, {"B", 90 * ms},
+		{"A", 100 * ms}, {"B", 120 * ms}, {"B", 150 * ms}, {"B", 180 * ms}, {"A", 200 * ms},
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Call %d: expected %v, got %v", i, expected[i], calls[i])
+		}
 	}
+}
 
-	// Check if the function exited in a reasonable time frame
-	if elapsed < sleepDuration-200*time.Millisecond || elapsed > sleepDuration+300*time.Millisecond {
-		t.Errorf("Expected the game loop to run for about 1 second, but it ran for %v", elapsed)
+func TestGameLoopManagerOvershootCatchUp(t *testing.T) {
+	clock := &fakeClock{}
+	var calls []gameEventCall
+	g := GameLoopManager{Clock: clock}
+	stall := CreateGameEvent("Update", 10*time.Millisecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {
+		calls = append(calls, gameEventCall{event.Name, time.Duration(clock.Now())})
+		if event.CallCount == 2 {
+			// the second update stalls the loop for 4.5 periods
+			clock.Advance(45 * time.Millisecond)
+		}
+	})
+	g.Initialise([]GameEvent{stall}, 0, nil)
+
+	g.Start()
+	for len(calls) < 6 {
+		g.Iterate()
+	}
+
+	// the overshoot is clamped to one period, so only one extra update is made to catch up
+	ms := time.Millisecond
+	expected := []time.Duration{0, 10 * ms, 55 * ms, 55 * ms, 65 * ms, 75 * ms}
+	for i := range expected {
+		if calls[i].time != expected[i] {
+			t.Errorf("Call %d: expected %v, got %v", i, expected[i], calls[i].time)
+		}
+	}
+	if overshoot := time.Duration(g.GetEvent("Update").ElapsedOvershoot); overshoot != 10*ms {
+		t.Errorf("Expected the overshoot to be clamped to 10ms, got %v", overshoot)
+	}
+}
+
+func TestGameLoopManagerSleepUndershoot(t *testing.T) {
+	clock := &fakeClock{}
+	var calls []gameEventCall
+	g := GameLoopManager{Clock: clock}
+	g.Initialise([]GameEvent{
+		createLoggedGameEvent("Update", 20*time.Millisecond, 0, clock, &calls),
+	}, 5*time.Millisecond, nil)
+
+	g.Start()
+	g.Iterate() // update
+	g.Iterate() // sleep until 5ms before the next update
+	if len(clock.sleeps) != 1 || clock.sleeps[0] != 15*time.Millisecond {
+		t.Fatalf("Expected one sleep of 15ms, got %v", clock.sleeps)
+	}
+	g.Iterate() // too close to the next update to sleep
+	if g.NoSleepCount != 1 || len(clock.sleeps) != 1 {
+		t.Errorf("Expected to not sleep within the undershoot, got %d no sleeps", g.NoSleepCount)
+	}
+	clock.Advance(5 * time.Millisecond)
+	g.Iterate()
+	if len(calls) != 2 || calls[1].time != 20*time.Millisecond {
+		t.Errorf("Expected the second update at 20ms, got %v", calls)
+	}
+}
+
+func TestGameLoopManagerTimingHistory(t *testing.T) {
+	clock := &fakeClock{}
+	var calls []gameEventCall
+	g := GameLoopManager{Clock: clock}
+	g.Initialise([]GameEvent{
+		createLoggedGameEvent("A", 10*time.Millisecond, 2*time.Millisecond, clock, &calls),
+		createLoggedGameEvent("B", 10*time.Millisecond, 3*time.Millisecond, clock, &calls),
+	}, 0, nil)
+	histories := g.TimingHistories()
+
+	g.Start()
+	for len(calls) < 4 {
+		g.Iterate()
+	}
+
+	ms := time.Millisecond.Nanoseconds()
+	// B is due with A, so always starts late by the time A takes
+	for name, expected := range map[string][2][]int64{
+		"A": {{2 * ms, 2 * ms}, {0, 0}},
+		"B": {{3 * ms, 3 * ms}, {2 * ms, 2 * ms}},
+	} {
+		durations, jitters := histories[name].Durations(), histories[name].Jitters()
+		if !slices.Equal(durations, expected[0]) || !slices.Equal(jitters, expected[1]) {
+			t.Errorf("Expected %s durations %v and jitters %v, got %v and %v", name, expected[0], expected[1], durations, jitters)
+		}
+	}
+
+	csv := string(TimingHistoriesToCSV(histories))
+	expectedCSV := "event,call,duration_ns,jitter_ns\n" +
+		"A,0,2000000,0\nA,1,2000000,0\n" +
+		"B,0,3000000,2000000\nB,1,3000000,2000000\n"
+	if csv != expectedCSV {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expectedCSV, csv)
+	}
+}
+
+func TestTimingHistoryPercentiles(t *testing.T) {
+	h := TimingHistory{}
+	// fill the history twice so the first values are overwritten
+	for i := int64(1); i <= 2*timingHistoryLength; i++ {
+		h.Add(i*time.Millisecond.Nanoseconds(), 0)
+	}
+	if h.Len() != timingHistoryLength || h.Durations()[0] != (timingHistoryLength+1)*time.Millisecond.Nanoseconds() {
+		t.Fatalf("Expected the oldest of %d values to be overwritten, got %d values from %v",
+			timingHistoryLength, h.Len(), time.Duration(h.Durations()[0]))
+	}
+
+	// the values are 257ms to 512ms
+	expected := TimingSummary{P50: 384 * time.Millisecond, P95: 500 * time.Millisecond, P99: 510 * time.Millisecond, Max: 512 * time.Millisecond}
+	if summary := h.DurationSummary(); summary != expected {
+		t.Errorf("Expected %v, got %v", expected, summary)
+	}
+	if summary := (&TimingHistory{}).DurationSummary(); summary != (TimingSummary{}) {
+		t.Errorf("Expected an empty history to have a zero summary, got %v", summary)
+	}
+}
+
+func TestRunUpdateEventSpeed(t *testing.T) {
+	scene := Scene{GameState: Playing, SimulationWorkers: 1, SpeedMultiplier: 1}
+	period := (1000 * time.Millisecond).Nanoseconds()
+
+	ChangeSpeed(&scene, false)
+	numSteps := 0
+	for i := 0; i < 8; i++ {
+		numSteps += RunUpdateEvent(&scene, period)
+	}
+	if scene.SpeedMultiplier != 0.5 || numSteps != 4 {
+		t.Errorf("Expected 4 steps in 8 events at x0.5, got %d at x%g", numSteps, scene.SpeedMultiplier)
+	}
+
+	ChangeSpeed(&scene, true)
+	ChangeSpeed(&scene, true)
+	if numSteps := RunUpdateEvent(&scene, period); numSteps != 2 {
+		t.Errorf("Expected 2 steps per event at x2, got %d", numSteps)
+	}
+
+	// run 10 steps then pause, even at max speed
+	scene.GameState = Pausing
+	scene.StepsToRun = 10
+	scene.MaxSpeed = true
+	iteration := scene.Iteration
+	if numSteps := RunUpdateEvent(&scene, period); numSteps != 10 {
+		t.Errorf("Expected 10 steps, got %d", numSteps)
+	}
+	if scene.GameState != Paused || scene.Iteration != iteration+10 {
+		t.Errorf("Expected to pause after 10 steps, got %v after %d steps", scene.GameState, scene.Iteration-iteration)
+	}
+	if numSteps := RunUpdateEvent(&scene, period); numSteps != 0 {
+		t.Errorf("Expected no steps while paused, got %d", numSteps)
+	}
+}
+
+func TestRateGameEventPeriod(t *testing.T) {
+	rate := 10
+	noop := func(event *GameEvent, gameLoopManager *GameLoopManager) {}
+	g := GameLoopManager{}
+	g.Initialise([]GameEvent{
+		CreateRateGameEvent("Update", func() int { return rate }, noop),
+		CreateGameEvent("Render", time.Second, noop),
+		CreateGameEvent("Statistics", time.Second, noop),
+	}, 0, make(chan struct{}))
+
+	if period := time.Duration(g.Events[0].Period); period != 100*time.Millisecond {
+		t.Errorf("Expected a period of 100ms, got %v", period)
+	}
+	rate = 20
+	g.Iterate()
+	if period := time.Duration(g.Events[0].Period); period != 50*time.Millisecond {
+		t.Errorf("Expected the period to change to 50ms, got %v", period)
+	}
+}
+
+func TestGameLoopManagerDynamicEvents(t *testing.T) {
+	quit := make(chan struct{})
+	numExtraCalls := 0
+	extra := CreateGameEvent("Extra", time.Millisecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {
+		numExtraCalls++
+		if numExtraCalls == 3 {
+			close(quit)
+		}
+	})
+
+	g := GameLoopManager{}
+	var histories map[string]*TimingHistory
+	g.OnEventsChanged = func(g *GameLoopManager) { histories = g.TimingHistories() }
+	g.Initialise([]GameEvent{
+		CreateGameEvent("Start", time.Millisecond, func(event *GameEvent, gameLoopManager *GameLoopManager) {
+			// replace this event with the extra event
+			gameLoopManager.AddEvent(extra)
+			if !gameLoopManager.RemoveEvent("Start") {
+				t.Errorf("Expected the Start event to be removed")
+			}
+		}),
+	}, 0, quit)
+
+	done := make(chan struct{})
+	go func() {
+		g.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the added event to quit the game loop")
+	}
+
+	if g.GetEvent("Start") != nil || g.GetEvent("Extra") == nil {
+		t.Errorf("Expected only the Extra event, got %d events", len(g.Events))
+	}
+	if _, hasExtra := histories["Extra"]; !hasExtra || len(histories) != 1 {
+		t.Errorf("Expected the timing histories to follow the added and removed events, got %v", histories)
+	}
+	if g.RemoveEvent("Missing") {
+		t.Errorf("Expected removing a missing event to return false")
 	}
 }
@@ -0,0 +1,170 @@
+package core
+
+// the number of ticks that can be rewound
+const historyLength = 1024
+
+type blockDelta struct {
+	index  uint16
+	before Block
+	after  Block
+}
+
+// tickDelta is every change made to the world by one step, including user inputs
+type tickDelta struct {
+	iteration   int // scene iteration before the step
+	changes     []blockDelta
+	rulesBefore WorldRules
+	rulesAfter  WorldRules
+}
+
+// History stores the changes of recent ticks, so the world can be rewound and replayed.
+// Scheduled ticks are not stored, so rewinding in ScheduledTicks mode drops pending ticks.
+type History struct {
+	deltas [historyLength]tickDelta // ring buffer
+	first  int
+	count  int
+	undone int // the number of ticks rewound, which can be replayed with Forward
+	// the world at the current position in history
+	blocks      [WorldSize]Block
+	rules       WorldRules
+	iteration   int
+	hasBaseline bool
+}
+
+func (h *History) Reset() {
+	*h = History{}
+}
+
+// NumTicks returns the number of ticks that can be rewound, and the number rewound
+func (h *History) NumTicks() (int, int) {
+	return h.count - h.undone, h.undone
+}
+
+func (h *History) delta(i int) *tickDelta {
+	return &h.deltas[(h.first+i)%historyLength]
+}
+
+func (h *History) setBaseline(scene *Scene) {
+	h.Reset()
+	h.blocks = scene.World.Blocks
+	h.rules = scene.World.Rules
+	h.iteration = scene.Iteration
+	h.hasBaseline = true
+}
+
+// Begin is called before a step, it restarts the history if the scene was changed
+// to a different iteration, such as by a reset
+func (h *History) Begin(scene *Scene) {
+	if !h.hasBaseline || scene.Iteration != h.iteration {
+		h.setBaseline(scene)
+	}
+}
+
+// Record is called after a step, stores the changes made since the last step.
+// A step is only simulated while rewound if the world was changed, which discards the rewound ticks.
+func (h *History) Record(scene *Scene) {
+	if !h.hasBaseline || scene.Iteration != h.iteration+1 {
+		h.setBaseline(scene)
+		return
+	}
+	if h.undone > 0 {
+		scene.BranchHistory()
+	}
+
+	d := tickDelta{
+		iteration:   h.iteration,
+		rulesBefore: h.rules,
+		rulesAfter:  scene.World.Rules,
+	}
+	for i, b := range scene.World.Blocks {
+		if b != h.blocks[i] {
+			d.changes = append(d.changes, blockDelta{uint16(i), h.blocks[i], b})
+		}
+	}
+
+	if h.count == historyLength {
+		h.first = (h.first + 1) % historyLength
+		h.count--
+	}
+	*h.delta(h.count) = d
+	h.count++
+	h.blocks = scene.World.Blocks
+	h.rules = scene.World.Rules
+	h.iteration = scene.Iteration
+}
+
+// restore sets the scene to the current position in history
+func (h *History) restore(scene *Scene) {
+	w := &scene.World
+	for i, b := range h.blocks {
+		if w.Blocks[i] != b {
+			w.SetBlock(w.GetPosition(i), b)
+		}
+	}
+	w.Rules = h.rules
+	scene.Iteration = h.iteration
+	scene.InputLog.seekReplay(h.iteration)
+	scene.Scheduler.Reset()
+	scene.PeriodDetector.Reset()
+	scene.SubUpdateOscillation = nil
+}
+
+// Rewind moves the scene back n ticks, returns the number of ticks rewound.
+// Changes made since the last step are discarded.
+func (h *History) Rewind(scene *Scene, n int) int {
+	i := 0
+	for ; i < n && h.undone < h.count; i++ {
+		d := h.delta(h.count - h.undone - 1)
+		for _, c := range d.changes {
+			h.blocks[c.index] = c.before
+		}
+		h.rules = d.rulesBefore
+		h.iteration = d.iteration
+		h.undone++
+	}
+	if h.hasBaseline {
+		h.restore(scene)
+	}
+	return i
+}
+
+// Forward replays n rewound ticks, returns the number of ticks replayed.
+// Nothing is replayed if the world was changed after rewinding.
+func (h *History) Forward(scene *Scene, n int) int {
+	if scene.World.Blocks != h.blocks || scene.World.Rules != h.rules {
+		return 0
+	}
+	i := 0
+	for ; i < n && h.undone > 0; i++ {
+		d := h.delta(h.count - h.undone)
+		for _, c := range d.changes {
+			h.blocks[c.index] = c.after
+		}
+		h.rules = d.rulesAfter
+		h.iteration = d.iteration + 1
+		h.undone--
+	}
+	if i > 0 {
+		h.restore(scene)
+	}
+	return i
+}
+
+// BranchHistory discards the rewound ticks so the simulation continues from the current
+// position in history, along with any inputs recorded on those ticks
+func (scene *Scene) BranchHistory() {
+	h := &scene.History
+	if h.undone == 0 {
+		return
+	}
+	h.count -= h.undone
+	h.undone = 0
+	if !scene.InputLog.isReplaying {
+		actions := scene.InputLog.Actions
+		i := len(actions)
+		for i > 0 && actions[i-1].Tick >= h.iteration {
+			i--
+		}
+		scene.InputLog.Actions = actions[:i]
+	}
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type InputActionType int
+
+const (
+	ToggleLeverAction InputActionType = iota
+	PlaceBlockAction
+	DestroyBlockAction
+	ToggleQuasiConnectivityAction
+	ToggleSimulationModeAction
+)
+
+func (t InputActionType) String() string {
+	switch t {
+	case ToggleLeverAction:
+		return "ToggleLever"
+	case PlaceBlockAction:
+		return "PlaceBlock"
+	case DestroyBlockAction:
+		return "DestroyBlock"
+	case ToggleQuasiConnectivityAction:
+		return "ToggleQuasiConnectivity"
+	case ToggleSimulationModeAction:
+		return "ToggleSimulationMode"
+	default:
+		panic("InputActionType not implemented")
+	}
+}
+
+// InputAction is a user action that changes the world, applied before the step of Tick
+type InputAction struct {
+	Tick     int
+	Type     InputActionType
+	Position Vec3
+	Block    Block // the block placed by PlaceBlockAction
+}
+
+// inputActionJSON stores the block of an action by its type, as blocks are interfaces
+type inputActionJSON struct {
+	Tick       int
+	Type       InputActionType
+	Position   Vec3
+	BlockType  string          `json:",omitempty"`
+	BlockState json.RawMessage `json:",omitempty"`
+}
+
+func (a InputAction) MarshalJSON() ([]byte, error) {
+	aj := inputActionJSON{Tick: a.Tick, Type: a.Type, Position: a.Position}
+	if a.Block != nil {
+		var err error
+		aj.BlockType, aj.BlockState, err = encodeBlock(a.Block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(aj)
+}
+
+func (a *InputAction) UnmarshalJSON(data []byte) error {
+	var aj inputActionJSON
+	if err := json.Unmarshal(data, &aj); err != nil {
+		return err
+	}
+	*a = InputAction{Tick: aj.Tick, Type: aj.Type, Position: aj.Position}
+	if aj.BlockType != "" {
+		b, err := decodeBlock(aj.BlockType, aj.BlockState)
+		if err != nil {
+			return fmt.Errorf("input at tick %d: %w", aj.Tick, err)
+		}
+		a.Block = b
+	}
+	return nil
+}
+
+// InputLog records the actions of a session, so it can be replayed against
+// the same starting world
+type InputLog struct {
+	// the settings at the start of the session
+	Rules          WorldRules
+	SimulationMode SimulationMode
+	Actions        []InputAction
+	// replay state
+	isReplaying bool
+	next        int
+}
+
+func (l *InputLog) IsReplaying() bool {
+	return l.isReplaying
+}
+
+// StartRecording clears the log, and records a new session with the scene's settings
+func (scene *Scene) StartRecording() {
+	scene.InputLog = InputLog{Rules: scene.World.Rules, SimulationMode: scene.SimulationMode}
+}
+
+// StartReplay replays a log against the scene's world, which should be the world the log was
+// recorded from at tick 0. New actions are not recorded until the replay ends.
+func (scene *Scene) StartReplay(l InputLog) {
+	scene.InputLog = l
+	scene.InputLog.isReplaying = true
+	scene.InputLog.next = 0
+	scene.World.SetQuasiConnectivity(l.Rules.QuasiConnectivity)
+	scene.SimulationMode = l.SimulationMode
+	scene.Scheduler.Reset()
+}
+
+// seekReplay moves a running replay to the first action due on or after tick,
+// so rewinding or forwarding the history replays the actions of those ticks again
+func (l *InputLog) seekReplay(tick int) {
+	if !l.isReplaying {
+		return
+	}
+	l.next = 0
+	for l.next < len(l.Actions) && l.Actions[l.next].Tick < tick {
+		l.next++
+	}
+}
+
+func (scene *Scene) applyInput(a InputAction) bool {
+	switch a.Type {
+	case ToggleLeverAction:
+		return toggleLever(a.Position, &scene.World)
+	case PlaceBlockAction:
+		return scene.World.SetBlock(a.Position, a.Block)
+	case DestroyBlockAction:
+		return scene.World.SetBlock(a.Position, Air{})
+	case ToggleQuasiConnectivityAction:
+		scene.World.SetQuasiConnectivity(!scene.World.Rules.QuasiConnectivity)
+	case ToggleSimulationModeAction:
+		if scene.SimulationMode == CellularAutomata {
+			scene.SimulationMode = ScheduledTicks
+		} else {
+			scene.SimulationMode = CellularAutomata
+		}
+		scene.Scheduler.Reset()
+	}
+	return true
+}
+
+// Input applies an action at the current tick, recording it unless a replay is running.
+// An input after rewinding branches the history.
+func (scene *Scene) Input(a InputAction) bool {
+	scene.BranchHistory()
+	a.Tick = scene.Iteration
+	hasApplied := scene.applyInput(a)
+	if hasApplied && !scene.InputLog.isReplaying {
+		scene.InputLog.Actions = append(scene.InputLog.Actions, a)
+	}
+	return hasApplied
+}
+
+// ReplayInputs applies the logged actions due on the current tick, returns true if any were applied
+func ReplayInputs(scene *Scene) bool {
+	l := &scene.InputLog
+	if !l.isReplaying {
+		return false
+	}
+	hasApplied := false
+	for l.next < len(l.Actions) && l.Actions[l.next].Tick <= scene.Iteration {
+		if scene.applyInput(l.Actions[l.next]) {
+			hasApplied = true
+		}
+		l.next++
+	}
+	if l.next >= len(l.Actions) {
+		l.isReplaying = false
+	}
+	return hasApplied
+}
+
+func EncodeInputLog(l *InputLog) ([]byte, error) {
+	return json.MarshalIndent(l, "", "  ")
+}
+
+func DecodeInputLog(data []byte) (InputLog, error) {
+	var l InputLog
+	if err := json.Unmarshal(data, &l); err != nil {
+		return InputLog{}, fmt.Errorf("error reading input log JSON: %w", err)
+	}
+	return l, nil
+}
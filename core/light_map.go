@@ -0,0 +1,129 @@
+package core
+
+const MaxLightLevel = 15
+
+// minBlockLight is the brightness of a face with no block light, when only block light is shown
+const minBlockLight = 0.5
+
+// blockLightFactor returns the brightness of a face with the shade unlit, brightened towards
+// full brightness by a light level. Block light only adds light, so unlit faces keep their shade.
+func blockLightFactor(shade float64, level int) float64 {
+	return shade + (1-shade)*float64(level)/MaxLightLevel
+}
+
+var lightDirections = [...]Direction{Up, Down, Left, Right, Front, Back}
+
+// LightMap stores the block light level, 0 to 15, of every position in the world. Light spreads
+// from light emitting blocks, losing one level per block, through blocks which are not opaque.
+type LightMap struct {
+	levels  [WorldSize]uint8
+	isBuilt bool
+	// reused between updates
+	removeQueue []lightNode
+	addQueue    []int
+}
+
+type lightNode struct {
+	index int
+	level uint8
+}
+
+func (l *LightMap) Reset() {
+	*l = LightMap{}
+}
+
+// Level returns the light level at p, and 0 outside the world
+func (l *LightMap) Level(p Vec3) int {
+	if p.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
+		return 0
+	}
+	return int(l.levels[p.Z*WorldWidth*WorldHeight+p.Y*WorldWidth+p.X])
+}
+
+func lightEmitted(b Block) uint8 {
+	if e, isEmitting := b.(LightEmittingBlock); isEmitting {
+		return uint8(min(max(e.LightLevel(), 0), MaxLightLevel))
+	}
+	return 0
+}
+
+// canLightEnter returns whether light moving in direction d can enter block b
+func canLightEnter(b Block, d Direction) bool {
+	o, isOpaque := b.(OpaqueBlock)
+	return !isOpaque || !o.IsOpaqueInDirection(d.GetOppositeDirection())
+}
+
+// Update recalculates the light levels around the changed blocks, or every light level on the first update
+func (l *LightMap) Update(w *World, changed []int) {
+	if !l.isBuilt {
+		l.levels = [WorldSize]uint8{}
+		for i, b := range w.Blocks {
+			if e := lightEmitted(b); e > 0 {
+				l.levels[i] = e
+				l.addQueue = append(l.addQueue, i)
+			}
+		}
+		l.isBuilt = true
+		l.spread(w)
+		return
+	}
+
+	// remove the light that may have come from the changed blocks
+	for _, i := range changed {
+		l.removeQueue = append(l.removeQueue, lightNode{i, l.levels[i]})
+		l.levels[i] = 0
+	}
+	for head := 0; head < len(l.removeQueue); head++ {
+		node := l.removeQueue[head]
+		p := w.GetPosition(node.index)
+		for _, d := range lightDirections {
+			n := p.Move(d)
+			if n.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
+				continue
+			}
+			ni := w.GetIndex(n)
+			level := l.levels[ni]
+			if level > 0 && level < node.level {
+				// lit by the removed light
+				l.removeQueue = append(l.removeQueue, lightNode{ni, level})
+				l.levels[ni] = 0
+			} else if level > 0 {
+				// lit by another source, which spreads back into the removed area
+				l.addQueue = append(l.addQueue, ni)
+			}
+		}
+	}
+	// sources whose light was removed keep their light, and changed blocks may now be sources
+	for _, node := range l.removeQueue {
+		if e := lightEmitted(w.Blocks[node.index]); e > l.levels[node.index] {
+			l.levels[node.index] = e
+			l.addQueue = append(l.addQueue, node.index)
+		}
+	}
+	l.removeQueue = l.removeQueue[:0]
+	l.spread(w)
+}
+
+// spread floods the light of the queued positions to their neighbours
+func (l *LightMap) spread(w *World) {
+	for head := 0; head < len(l.addQueue); head++ {
+		i := l.addQueue[head]
+		level := l.levels[i]
+		if level <= 1 {
+			continue
+		}
+		p := w.GetPosition(i)
+		for _, d := range lightDirections {
+			n := p.Move(d)
+			if n.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
+				continue
+			}
+			ni := w.GetIndex(n)
+			if l.levels[ni] < level-1 && canLightEnter(w.Blocks[ni], d) {
+				l.levels[ni] = level - 1
+				l.addQueue = append(l.addQueue, ni)
+			}
+		}
+	}
+	l.addQueue = l.addQueue[:0]
+}
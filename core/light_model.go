@@ -0,0 +1,94 @@
+package core
+
+import "math"
+
+type LightMode int
+
+const (
+	// SunAndBlockLight shades faces by the sun, brightened by block light
+	SunAndBlockLight LightMode = iota
+	// SunLight shades faces by the angle between their normal and the sun
+	SunLight
+	// BlockLight shades faces by block light only
+	BlockLight
+	// Unlit draws textures at full brightness, which makes builds easier to read
+	Unlit
+	numLightModes
+)
+
+func (m LightMode) String() string {
+	switch m {
+	case SunAndBlockLight:
+		return "sun+blocks"
+	case SunLight:
+		return "sun"
+	case BlockLight:
+		return "blocks"
+	case Unlit:
+		return "unlit"
+	default:
+		panic("LightMode not implemented")
+	}
+}
+
+// LightModel calculates the shade of a face, from 0 (black) to 1 (the texture's colour)
+type LightModel struct {
+	Mode             LightMode
+	SunDirection     Point3D // normalised direction towards the sun
+	Ambient          float64 // shade of faces facing away from the sun
+	AmbientOcclusion bool    // darken the corners of faces next to opaque blocks
+}
+
+func DefaultLightModel() LightModel {
+	return LightModel{
+		Mode:             SunAndBlockLight,
+		SunDirection:     Normalize(Point3D{-0.3, 0.5, 0.8}),
+		Ambient:          0.7,
+		AmbientOcclusion: true,
+	}
+}
+
+func (m LightModel) String() string {
+	if m.AmbientOcclusion && m.Mode != Unlit {
+		return m.Mode.String() + "+ao"
+	}
+	return m.Mode.String()
+}
+
+// Shade returns the shade of a face with the normal, lit by the block light level
+func (m *LightModel) Shade(normal Point3D, blockLight int) float64 {
+	sun := m.Ambient + max(0, min(DotProduct(normal, m.SunDirection), 1))*(1-m.Ambient)
+	switch m.Mode {
+	case SunAndBlockLight:
+		return blockLightFactor(sun, blockLight)
+	case SunLight:
+		return sun
+	case BlockLight:
+		return blockLightFactor(minBlockLight, blockLight)
+	default:
+		return 1
+	}
+}
+
+// occlusion returns the ambient occlusion of a triangle's vertices, which are not darkened if
+// ambient occlusion is off or the model is unlit
+func (m *LightModel) occlusion(ao [3]float64) [3]float64 {
+	if !m.AmbientOcclusion || m.Mode == Unlit {
+		return noOcclusion
+	}
+	return ao
+}
+
+func (m *LightModel) NextMode() {
+	m.Mode = (m.Mode + 1) % numLightModes
+}
+
+// RotateSun turns the sun around the vertical axis by angle radians
+func (m *LightModel) RotateSun(angle float64) {
+	m.SunDirection = Normalize(m.SunDirection.RotateY(angle))
+}
+
+// sunAzimuth returns the angle of the sun around the vertical axis, in degrees
+func (m *LightModel) sunAzimuth() float64 {
+	return math.Mod(RadToDeg(math.Atan2(m.SunDirection.X, m.SunDirection.Z))+360, 360)
+}
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"image"
+	"image/color"
+)
+
+// chunks are whole z layers of the world, so drawing the chunks in order
+// draws the triangles in the same order as drawing every block in order
+const meshChunkDepth = 2
+const numMeshChunks = WorldDepth / meshChunkDepth
+
+type meshTriangle struct {
+	v     [3]Vertex // uvs are in tilemap pixels
+	color color.RGBA
+	// the positions whose light level lights the triangle, the block and the block its face
+	// faces, -1 if outside the world
+	lightIndices [2]int
+	ao           [3]float64 // brightness of each vertex from ambient occlusion
+	translucent  bool       // the texture has pixels which are not opaque, so is drawn blended
+}
+
+// lightLevel returns the block light level of the brighter of the triangle's light positions
+func (t *meshTriangle) lightLevel(lightMap *LightMap) int {
+	level := 0
+	for _, i := range t.lightIndices {
+		if i >= 0 {
+			level = max(level, int(lightMap.levels[i]))
+		}
+	}
+	return level
+}
+
+// meshBlock is the triangles of one block, and their bounds
+type meshBlock struct {
+	first, end int // range of the chunk's triangles
+	min, max   Point3D
+}
+
+type meshChunk struct {
+	triangles []meshTriangle
+	blocks    []meshBlock
+	min, max  Point3D // bounds of the blocks
+	isValid   bool
+}
+
+// CullingStats counts the blocks with triangles culled by the camera's frustum in the last frame
+type CullingStats struct {
+	BlocksDrawn  int
+	BlocksCulled int
+	ChunksCulled int
+}
+
+// MeshCache stores the triangles of each chunk of the world, a chunk is only rebuilt
+// when a block in or next to it changes. Reset it if the tilemap changes.
+type MeshCache struct {
+	chunks     [numMeshChunks]meshChunk
+	NumRebuilt int // chunks rebuilt by the last Update
+	// whether each area of the tilemap used by a triangle has translucent pixels
+	translucentAreas map[image.Rectangle]bool
+}
+
+func (m *MeshCache) Reset() {
+	for i := range m.chunks {
+		m.chunks[i].isValid = false
+	}
+	m.translucentAreas = nil
+}
+
+func (m *MeshCache) isTranslucent(texture *image.RGBA, v1, v2, v3 Vertex) bool {
+	if m.translucentAreas == nil {
+		m.translucentAreas = make(map[image.Rectangle]bool)
+	}
+	area := uvBounds(v1, v2, v3)
+	translucent, isKnown := m.translucentAreas[area]
+	if !isKnown {
+		translucent = hasTranslucentPixels(texture, area)
+		m.translucentAreas[area] = translucent
+	}
+	return translucent
+}
+
+func meshChunkOfIndex(i int) int {
+	return i / (WorldWidth * WorldHeight * meshChunkDepth)
+}
+
+// Update rebuilds the chunks affected by the changed blocks, and any chunk not built yet
+func (m *MeshCache) Update(scene *Scene, changed []int) {
+	w := &scene.World
+	for _, i := range changed {
+		// the faces drawn depend on the neighbours, which may be in the chunk in front or behind
+		z := w.GetPosition(i).Z
+		for _, nz := range [...]int{z - 1, z, z + 1} {
+			if nz >= 0 && nz < WorldDepth {
+				m.chunks[nz/meshChunkDepth].isValid = false
+			}
+		}
+	}
+
+	m.NumRebuilt = 0
+	for c := range m.chunks {
+		if !m.chunks[c].isValid {
+			m.buildChunk(c, scene)
+			m.NumRebuilt++
+		}
+	}
+}
+
+func (m *MeshCache) buildChunk(c int, scene *Scene) {
+	chunk := &m.chunks[c]
+	chunk.triangles = chunk.triangles[:0]
+	chunk.blocks = chunk.blocks[:0]
+	chunkSize := WorldWidth * WorldHeight * meshChunkDepth
+	for i := c * chunkSize; i < (c+1)*chunkSize; i++ {
+		block := scene.World.Blocks[i]
+		rb, isRenderable := block.(WireRenderBlock)
+		if !isRenderable {
+			continue
+		}
+
+		// if a block and is neighbour are opaque on their shared face then dont render
+		var faces []int
+		position := Convert1DTo3D(i)
+		opaqueBlock, isOpaqueBlock := block.(OpaqueBlock)
+		if skipAdjacentFaces && isOpaqueBlock {
+			for face, direction := range cuboidFaceDirections {
+				if !opaqueBlock.IsOpaqueInDirection(direction) {
+					continue
+				}
+				np := position.ToVec3().Move(direction)
+				neighbour := scene.World.GetBlock(np)
+				nOpaqueBlock, nIsOpaqueBlock := neighbour.(OpaqueBlock)
+				if !nIsOpaqueBlock || !nOpaqueBlock.IsOpaqueInDirection(direction.GetOppositeDirection()) {
+					faces = append(faces, face)
+				}
+			}
+		} else {
+			faces = []int{0, 1, 2, 3, 4, 5}
+		}
+
+		first := len(chunk.triangles)
+		for _, cuboid := range rb.ToCuboids(scene) {
+			movedCuboid := cuboid.Move(position)
+			forEachCuboidTriangle(movedCuboid, &scene.Tilemap, &faces, func(face int, v1, v2, v3 Vertex) {
+				direction := cuboidFaceDirections[face]
+				lightIndices := [2]int{i, -1}
+				if facing := position.ToVec3().Move(direction); !facing.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
+					lightIndices[1] = scene.World.GetIndex(facing)
+				}
+				// only whole faces of a block reach the corners of the neighbouring blocks
+				ao := noOcclusion
+				if isOpaqueBlock && opaqueBlock.IsOpaqueInDirection(direction) {
+					ao = triangleAmbientOcclusion(&scene.World, position.ToVec3(), direction, v1, v2, v3)
+				}
+				translucent := m.isTranslucent(&scene.Tilemap.Image, v1, v2, v3)
+				chunk.triangles = append(chunk.triangles, meshTriangle{[3]Vertex{v1, v2, v3}, movedCuboid.Color, lightIndices, ao, translucent})
+			})
+		}
+		if len(chunk.triangles) > first {
+			mb := meshBlock{first: first, end: len(chunk.triangles)}
+			mb.min, mb.max = triangleBounds(chunk.triangles[first:])
+			chunk.blocks = append(chunk.blocks, mb)
+		}
+	}
+	if len(chunk.triangles) > 0 {
+		chunk.min, chunk.max = triangleBounds(chunk.triangles)
+	}
+	chunk.isValid = true
+}
+
+func triangleBounds(triangles []meshTriangle) (Point3D, Point3D) {
+	lo := triangles[0].v[0].Position
+	hi := lo
+	for _, t := range triangles {
+		for _, v := range t.v {
+			p := v.Position
+			lo = Point3D{min(lo.X, p.X), min(lo.Y, p.Y), min(lo.Z, p.Z)}
+			hi = Point3D{max(hi.X, p.X), max(hi.Y, p.Y), max(hi.Z, p.Z)}
+		}
+	}
+	return lo, hi
+}
+
+// forEachVisibleTriangle calls f with every triangle of the blocks inside the frustum, in block order
+func (m *MeshCache) forEachVisibleTriangle(frustum Frustum, f func(t *meshTriangle)) CullingStats {
+	stats := CullingStats{}
+	for c := range m.chunks {
+		chunk := &m.chunks[c]
+		if len(chunk.blocks) == 0 {
+			continue
+		}
+		if !frustum.IntersectsBox(chunk.min, chunk.max) {
+			stats.ChunksCulled++
+			stats.BlocksCulled += len(chunk.blocks)
+			continue
+		}
+		for _, b := range chunk.blocks {
+			if !frustum.IntersectsBox(b.min, b.max) {
+				stats.BlocksCulled++
+				continue
+			}
+			stats.BlocksDrawn++
+			for i := b.first; i < b.end; i++ {
+				f(&chunk.triangles[i])
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,77 @@
+package core
+
+// SubUpdateOscillation describes a step whose sub updates never stopped changing the world
+type SubUpdateOscillation struct {
+	Iteration   int    // scene iteration the oscillation was detected on
+	CycleLength int    // sub update iterations per cycle, 0 if no cycle was found
+	Positions   []Vec3 // blocks that keep flipping
+}
+
+// changedPositions runs the sub updates for a number of iterations and returns every position
+// that changed, then restores the world
+func (w *World) changedPositions(iterations int, workers int) []Vec3 {
+	saved := *w
+	defer func() { *w = saved }()
+
+	var positions []Vec3
+	hasChanged := [WorldSize]bool{}
+	for i := 0; i < iterations; i++ {
+		previous := w.Blocks
+		w.ParallelSubUpdateWorld(workers)
+		for j := range w.Blocks {
+			if w.Blocks[j] != previous[j] && !hasChanged[j] {
+				hasChanged[j] = true
+				positions = append(positions, w.GetPosition(j))
+			}
+		}
+	}
+	return positions
+}
+
+// most steps settle within a few sub update iterations, so the world is only hashed to look
+// for a cycle once it has gone this many iterations without settling
+const oscillationCheckIterations = 2
+
+// SubUpdateWorldUntilStable runs sub updates until no blocks change, or maxIterations is reached.
+// Returns the number of iterations that changed blocks, the total number of sub updates, and
+// when the world did not settle, the first cycle it entered.
+func (w *World) SubUpdateWorldUntilStable(maxIterations int, workers int) (int, int, *SubUpdateOscillation) {
+	// iteration each world state was last seen on
+	var seen map[uint64]int
+	var oscillation *SubUpdateOscillation
+	totalSubUpdates := 0
+	i := 0
+	for i < maxIterations {
+		numSubUpdates := w.ParallelSubUpdateWorld(workers)
+		totalSubUpdates += numSubUpdates
+		if numSubUpdates == 0 {
+			return i, totalSubUpdates, nil
+		}
+		i++
+
+		if oscillation != nil || i <= oscillationCheckIterations {
+			continue
+		}
+		if seen == nil {
+			// from now on the hash is updated from the blocks each pass changes
+			seen = make(map[uint64]int)
+			if !w.isHashTracked {
+				w.trackHash(true)
+				defer w.trackHash(false)
+			}
+		}
+		h := w.Hash()
+		if first, isRepeated := seen[h]; isRepeated {
+			cycleLength := i - first
+			oscillation = &SubUpdateOscillation{
+				CycleLength: cycleLength,
+				Positions:   w.changedPositions(cycleLength, workers),
+			}
+		}
+		seen[h] = i
+	}
+	if oscillation == nil {
+		oscillation = &SubUpdateOscillation{Positions: w.changedPositions(1, workers)}
+	}
+	return i, totalSubUpdates, oscillation
+}
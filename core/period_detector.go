@@ -0,0 +1,103 @@
+package core
+
+// the longest period that can be detected
+const periodHistoryLength = 1024
+
+// WorldRegion is the box of blocks from Min (inclusive) to Max (exclusive)
+type WorldRegion struct {
+	Min Vec3
+	Max Vec3
+}
+
+// PeriodDetector hashes the world after every tick to find when it enters a cycle,
+// such as a clock. A cycle is only reported once it has repeated. A world that stops
+// changing is stable rather than in a cycle, so a world that holds a state for a
+// few ticks is briefly reported as stable.
+type PeriodDetector struct {
+	Region *WorldRegion // only hash this region, or the whole world if nil
+	// hashes of the last periodHistoryLength ticks, indexed by tick
+	history [periodHistoryLength]uint64
+	count   int // number of ticks recorded
+	tick    int // the last tick recorded
+	// 0 if the world is not in a cycle
+	Period int
+	// true if the world has not changed since the previous tick
+	IsStable bool
+	// the first tick of the current cycle or stable state, -1 if the world is in neither
+	CycleStart int
+}
+
+// Reset forgets every recorded tick, but keeps the region
+func (d *PeriodDetector) Reset() {
+	*d = PeriodDetector{Region: d.Region, CycleStart: -1}
+}
+
+// SetRegion limits detection to a region of the world, nil for the whole world
+func (d *PeriodDetector) SetRegion(region *WorldRegion) {
+	d.Region = region
+	d.Reset()
+}
+
+func (d *PeriodDetector) hash(w *World) uint64 {
+	if d.Region == nil {
+		// the world keeps its hash up to date as blocks are set, rather than hashing every block each tick
+		w.trackHash(true)
+		return w.Hash()
+	}
+	return w.RegionHash(d.Region.Min, d.Region.Max)
+}
+
+func (d *PeriodDetector) hashAt(tick int) uint64 {
+	return d.history[tick%periodHistoryLength]
+}
+
+// repeatsSince returns the first tick from which every recorded tick until
+// the last one equals the tick period ticks before it
+func (d *PeriodDetector) repeatsSince(period int) int {
+	oldest := d.tick - min(d.count, periodHistoryLength) + 1
+	t := d.tick
+	for t-period >= oldest && d.hashAt(t) == d.hashAt(t-period) {
+		t--
+	}
+	return t - period + 1
+}
+
+// Record adds the state of the world at tick, ticks must be recorded in order.
+// Returns true if the world is in a cycle, which excludes being stable.
+func (d *PeriodDetector) Record(tick int, w *World) bool {
+	if d.count == 0 {
+		d.CycleStart = -1
+	}
+	d.history[tick%periodHistoryLength] = d.hash(w)
+	d.tick = tick
+	d.count++
+
+	// the current cycle or stable state continues
+	if d.Period > 0 && d.hashAt(tick) == d.hashAt(tick-d.Period) {
+		return true
+	}
+	if d.IsStable && d.hashAt(tick) == d.hashAt(tick-1) {
+		return false
+	}
+
+	// find the shortest period which has repeated at least once, a period of 1 is stable
+	d.Period = 0
+	d.IsStable = false
+	d.CycleStart = -1
+	for period := 1; period < min(d.count, periodHistoryLength); period++ {
+		if d.hashAt(tick) != d.hashAt(tick-period) {
+			continue
+		}
+		start := d.repeatsSince(period)
+		if tick-start+1 >= 2*period {
+			d.CycleStart = start
+			if period == 1 {
+				d.IsStable = true
+				return false
+			}
+			d.Period = period
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ type ImgPoint struct {
 	X, Y int
 	Z    float64
 	U, V float64
+	AO   float64 // ambient occlusion, divided like U and V
 }
 
 type Int_2D struct {
@@ -51,6 +51,24 @@ func UpdateInputPowerType(p Vec3, w *World) PowerType {
 	return inputPowerType
 }
 
+// IsInputPowered returns true if any neighbour of p powers the position p
+func IsInputPowered(p Vec3, w *World) bool {
+	return UpdateInputPowerType(p, w) != None
+}
+
+// IsQuasiConnectedPowered returns true if p is powered, or when quasi-connectivity
+// is enabled, if the position one above p is powered. It is a helper for pistons and
+// dispensers, which are not implemented yet, so no block in the world uses it.
+func IsQuasiConnectedPowered(p Vec3, w *World) bool {
+	if IsInputPowered(p, w) {
+		return true
+	}
+	if !w.Rules.QuasiConnectivity {
+		return false
+	}
+	return IsInputPowered(p.Move(Up), w)
+}
+
 func (b RedstoneLamp) SubUpdate(p Vec3, w *World) (Block, bool) {
 	var newInputPowerType PowerType = UpdateInputPowerType(p, w)
 	hasUpdated := newInputPowerType != b.InputPowerType
@@ -62,6 +80,18 @@ func (b RedstoneLamp) isPowered() bool {
 	return b.InputPowerType != None
 }
 
+// TickDelay turns the lamp on instantly but off after 4 ticks
+func (b RedstoneLamp) TickDelay(next Block) int {
+	if lamp, isLamp := next.(RedstoneLamp); isLamp && lamp.isPowered() {
+		return 0
+	}
+	return 4
+}
+
+func (b RedstoneLamp) TickPriority() TickPriority {
+	return NormalPriority
+}
+
 func (b RedstoneLamp) ToRune() rune {
 	return 'B'
 }
@@ -91,6 +121,13 @@ func (b RedstoneLamp) ToCuboids(scene *Scene) []Cuboid {
 	}
 }
 
+func (b RedstoneLamp) LightLevel() int {
+	if b.isPowered() {
+		return MaxLightLevel
+	}
+	return 0
+}
+
 func (b RedstoneLamp) IsOpaqueInDirection(d Direction) bool {
 	return true
 }
@@ -1,6 +1,6 @@
 package core
 
-import "image/color"
+import "fmt"
 
 type RedstoneTorch struct {
 	Direction Direction
@@ -36,6 +36,14 @@ func (b RedstoneTorch) Update(p Vec3, w *World) (Block, bool) {
 	return b, hasUpdated
 }
 
+func (b RedstoneTorch) TickDelay(next Block) int {
+	return 2
+}
+
+func (b RedstoneTorch) TickPriority() TickPriority {
+	return NormalPriority
+}
+
 func (b RedstoneTorch) OutputsPowerInDirection(d Direction) bool {
 	return d != b.Direction.GetOppositeDirection() && b.IsPowered
 }
@@ -48,88 +56,29 @@ func (b RedstoneTorch) OutputsWeakPowerInDirection(d Direction) bool {
 	return b.OutputsPowerInDirection(d) && !b.OutputsStrongPowerInDirection(d)
 }
 
-func (b RedstoneTorch) ToRune() rune {
+func (b RedstoneTorch) LightLevel() int {
 	if b.IsPowered {
-		return 'T'
-	} else {
-		return 't'
+		return 7
 	}
+	return 0
 }
 
-func (b RedstoneTorch) ToCuboids(scene *Scene) []Cuboid {
-	s := Point3DFromScalar(16)
-	// var c uint8
-	var tex string
+func (b RedstoneTorch) ToRune() rune {
 	if b.IsPowered {
-		// c = 255
-		tex = "redstone_torch"
+		return 'T'
 	} else {
-		// c = 150
-		tex = "redstone_torch_off"
-	}
-
-	torch_cuboid := MakeAxisAlignedCuboid(
-		Point3D{7, 0, 7}.Divide(s),
-		Point3D{9, 10, 9}.Divide(s),
-		color.RGBA{160, 127, 81, 255},
-		CreateCuboidUVs(7, 6, 2, 10, tex, scene),
-	)
-
-	// torch_base := MakeAxisAlignedCuboid(
-	// 	Point3D{7, 0, 7}.Divide(s),
-	// 	Point3D{9, 7, 9}.Divide(s),
-	// 	color.RGBA{160, 127, 81, 255},
-	// 	CreateCuboidUVs(7, 4, 2, 12, tex, scene),
-	// 	// MakeCuboidUVs(
-	// 	// 	[6]string{"redstone_torch", "test", "test", "test", "test", "test"},
-	// 	// 	scene,
-	// 	// ),
-	// )
-
-	// torch_head := MakeAxisAlignedCuboid(
-	// 	Point3D{7, 7, 7}.Divide(s),
-	// 	Point3D{9, 9, 9}.Divide(s),
-	// 	color.RGBA{c, 0, 0, 255},
-	// 	MakeCuboidUVs(
-	// 		[6]string{"test", "test", "test", "test", "test", "test"},
-	// 		scene,
-	// 	),
-	// )
-
-	cuboids := []Cuboid{
-		torch_cuboid,
-		// torch_head,
-	}
-
-	var ry, rz float64 = 0, 0
-	var offset Point3D = Point3D{0, 0, 0}
-	switch b.Direction {
-	case Left:
-		ry = 0
-		rz = 45
-		offset = Point3D{5, 2, 0}.Divide(s)
-	case Right:
-		ry = 180
-		rz = 45
-		offset = Point3D{-5, 2, 0}.Divide(s)
-	case Front:
-		ry = 90
-		rz = 45
-		offset = Point3D{0, 2, -5}.Divide(s)
-	case Back:
-		ry = 270
-		rz = 45
-		offset = Point3D{0, 2, 5}.Divide(s)
+		return 't'
 	}
+}
 
-	for j := range cuboids {
-		for i := 0; i < 8; i++ {
-			cuboid := &cuboids[j]
-			translate := Point3D{8, 3.5, 8}.Divide(s)
-			cuboid.vertices[i] = cuboid.vertices[i].Subtract(translate).RotateZ(DegToRad(rz)).RotateY(DegToRad(ry)).Add(translate).Add(offset)
-		}
-	}
+func (b RedstoneTorch) ModelName() string {
+	return "redstone_torch"
+}
 
-	return cuboids
+func (b RedstoneTorch) BlockState() string {
+	return fmt.Sprintf("facing=%s,lit=%t", b.Direction, b.IsPowered)
+}
 
+func (b RedstoneTorch) ToCuboids(scene *Scene) []Cuboid {
+	return scene.BlockModels.Cuboids(b, scene)
 }
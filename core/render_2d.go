@@ -194,12 +194,16 @@ type TextureMeta struct {
 type Tilemap struct {
 	Image image.RGBA
 	Metas map[string]TextureMeta
+	Mips  []*image.RGBA // smaller copies of Image, each half the size of the one before
 }
 
+// tilePadding is the width of the gutter around each tile in the tilemap, filled with the tile's
+// edge pixels so filtering and mip levels do not blend in neighbouring tiles
+const tilePadding = 4
+
 // TextureMeta and Tilemap structs defined as before
 
 func GenerateTilemap(dir string, tileSize int) (*Tilemap, error) {
-	const doPad = false
 	files, err := LoadAssets()
 	if err != nil {
 		return nil, err
@@ -228,14 +232,11 @@ func GenerateTilemap(dir string, tileSize int) (*Tilemap, error) {
 		return nil, fmt.Errorf("no PNG files found in directory: %s", dir)
 	}
 
-	padWidth := 0
-	if doPad {
-		padWidth = 1
-	}
+	padWidth := tilePadding
 
 	// Calculate dimensions for the tilemap with border
 	tilesPerDim := int(math.Ceil(math.Sqrt(float64(len(images)))))
-	tilemapSize := tilesPerDim * (tileSize + 2*padWidth) // Each tile includes a 1-pixel border on each side
+	tilemapSize := tilesPerDim * (tileSize + 2*padWidth) // Each tile includes a padWidth border on each side
 
 	// Create a new tilemap image with adjusted size
 	agg_image := image.NewRGBA(image.Rect(0, 0, tilemapSize, tilemapSize))
@@ -244,33 +245,21 @@ func GenerateTilemap(dir string, tileSize int) (*Tilemap, error) {
 	for i, img := range images {
 		// Calculate destination rectangle with border margin
 
-		x := (i%tilesPerDim)*(tileSize+2*padWidth) + padWidth // Leave 1-pixel margin for the border
+		x := (i%tilesPerDim)*(tileSize+2*padWidth) + padWidth // Leave a margin for the border
 		y := (i/tilesPerDim)*(tileSize+2*padWidth) + padWidth
 		dstRect := image.Rect(x, y, x+tileSize, y+tileSize)
 
 		// Draw the tile itself
 		draw.Draw(agg_image, dstRect, img, image.Point{0, 0}, draw.Over)
 
-		if doPad {
-			// Fill the border pixels around each tile
-			for bx := 0; bx < tileSize; bx++ {
-				// Top border
-				agg_image.Set(x+bx, y-1, img.At(bx, 0))
-				// Bottom border
-				agg_image.Set(x+bx, y+tileSize, img.At(bx, tileSize-1))
-			}
-			for by := 0; by < tileSize; by++ {
-				// Left border
-				agg_image.Set(x-1, y+by, img.At(0, by))
-				// Right border
-				agg_image.Set(x+tileSize, y+by, img.At(tileSize-1, by))
+		// Fill the border pixels around each tile with the nearest edge pixel
+		for by := -padWidth; by < tileSize+padWidth; by++ {
+			for bx := -padWidth; bx < tileSize+padWidth; bx++ {
+				if bx >= 0 && bx < tileSize && by >= 0 && by < tileSize {
+					continue
+				}
+				agg_image.Set(x+bx, y+by, img.At(min(max(bx, 0), tileSize-1), min(max(by, 0), tileSize-1)))
 			}
-
-			// Fill corners of the border with neighboring pixel colors
-			agg_image.Set(x-1, y-1, img.At(0, 0))                                 // Top-left corner
-			agg_image.Set(x+tileSize, y-1, img.At(tileSize-1, 0))                 // Top-right corner
-			agg_image.Set(x-1, y+tileSize, img.At(0, tileSize-1))                 // Bottom-left corner
-			agg_image.Set(x+tileSize, y+tileSize, img.At(tileSize-1, tileSize-1)) // Bottom-right corner
 		}
 
 		// Calculate normalized UV coordinates
@@ -285,7 +274,14 @@ func GenerateTilemap(dir string, tileSize int) (*Tilemap, error) {
 		textureMetas[nameWithoutExt] = TextureMeta{u, v, uWidth, vHeight}
 	}
 
-	return &Tilemap{*agg_image, textureMetas}, nil
+	// each level halves the padded tiles, which stay separate while their size is even
+	numMips := 0
+	for cellSize := tileSize + 2*padWidth; cellSize%2 == 0 && numMips < maxMipLevels; cellSize /= 2 {
+		numMips++
+	}
+	mips := GenerateMips(agg_image, numMips)
+
+	return &Tilemap{*agg_image, textureMetas, mips}, nil
 }
 
 func SaveImage(img image.Image, path string) error {
@@ -261,68 +261,62 @@ func DrawTriangle3D(
 	depthBuffer *DepthBuffer,
 	texture *image.RGBA,
 ) {
+	lightModel := DefaultLightModel()
+	drawTriangle3D(v1, v2, v3, noOcclusion, camera.Frustum(), img, clr, depthBuffer, singleLevelTexture(texture), false, &lightModel, 0)
+}
+
+// drawTriangle3D draws the triangle shaded by the light model, lit by the block light level and
+// darkened by the ambient occlusion of each vertex. If blend, the texture is alpha blended.
+func drawTriangle3D(
+	v1, v2, v3 Vertex,
+	ao [3]float64,
+	frustum Frustum,
+	img *image.RGBA,
+	clr color.RGBA,
+	depthBuffer *DepthBuffer,
+	texture *mipTexture,
+	blend bool,
+	lightModel *LightModel,
+	blockLight int,
+) {
+	imageSize := Point2D{float64(img.Bounds().Dx()), float64(img.Bounds().Dy())}
+	var points [maxClipVertices]ImgPoint
+	polygon, normal := projectTriangle(v1, v2, v3, lightModel.occlusion(ao), frustum, imageSize, &points)
+	if len(polygon) == 0 {
+		return
+	}
+	intensity := lightModel.Shade(normal, blockLight)
+	shadedColor := ShadeColor(clr, intensity)
+	// the clipped polygon is convex, so draw it as a fan of triangles
+	for i := 2; i < len(polygon); i++ {
+		DrawTriangle2D2(img, polygon[0], polygon[i-1], polygon[i], shadedColor, depthBuffer, texture, intensity, blend)
+	}
+}
+
+// projectTriangle clips the triangle to the frustum, and projects the vertices of the clipped polygon
+// to image coordinates, returned with the triangle's normal. Returns no vertices if the triangle faces
+// away from the camera or is outside the frustum.
+func projectTriangle(v1, v2, v3 Vertex, ao [3]float64, frustum Frustum, imageSize Point2D, out *[maxClipVertices]ImgPoint) ([]ImgPoint, Point3D) {
 	// Calculate the normal of the triangle
 	normal := CalculateNormal(v1.Position, v2.Position, v3.Position)
 	avgV := v1.Position.Add(v2.Position).Add(v3.Position).Divide(Point3D{3, 3, 3})
 
 	// plane not in direction of camera
-	if DotProduct(normal, Normalize(camera.Position.Subtract(avgV))) < 0 {
-		return
+	if DotProduct(normal, Normalize(frustum.camera.Position.Subtract(avgV))) < 0 {
+		return nil, normal
 	}
-	imageSize := Point2D{float64(img.Bounds().Dx()), float64(img.Bounds().Dy())}
-	// Project the 3D vertices to 2D screen coordinates
-	// p1 := ProjectPoint(v1.Position, camera, imageSize)
-	// p2 := ProjectPoint(v2.Position, camera, imageSize)
-	// p3 := ProjectPoint(v3.Position, camera, imageSize)
-
-	p1 := camera.Convert3DTo2D(v1.Position)
-	p2 := camera.Convert3DTo2D(v2.Position)
-	p3 := camera.Convert3DTo2D(v3.Position)
-
-	if p1 != nil && p2 != nil && p3 != nil {
-		k := 1.0 // cull edge thickness
-		if ((p1.X < -k || p1.X > k) || (p1.Y < -k || p1.Y > k)) &&
-			((p2.X < -k || p2.X > k) || (p2.Y < -k || p2.Y > k)) &&
-			((p3.X < -k || p3.X > k) || (p3.Y < -k || p3.Y > k)) {
-			return
-		}
-
-		halfWidth, halfHeight := imageSize.X/2, imageSize.Y/2
-		pi1 := Int_2D{
-			int(p1.X*halfWidth + halfWidth),
-			int(-p1.Y*halfHeight + halfHeight),
-		}
-		pi2 := Int_2D{
-			int(p2.X*halfWidth + halfWidth),
-			int(-p2.Y*halfHeight + halfHeight),
-		}
-		pi3 := Int_2D{
-			int(p3.X*halfWidth + halfWidth),
-			int(-p3.Y*halfHeight + halfHeight),
-		}
-
-		lightDirection := Normalize(Point3D{-0.3, 0.5, 0.8})
-		intensity := DotProduct(normal, lightDirection)
-		kShade := 0.7 // 0 = black, 1 = no shade
-		intensity = kShade + max(0, min(intensity, 1))*(1-kShade)
-		shadedColor := ShadeColor(clr, intensity)
-
-		d1 := calculateDepth(v1.Position, camera)
-		d2 := calculateDepth(v2.Position, camera)
-		d3 := calculateDepth(v3.Position, camera)
-
-		var w1, w2, w3 float64
-		if TexturePerspective {
-			w1, w2, w3 = d1, d2, d3
-		} else {
-			w1, w2, w3 = 1.0, 1.0, 1.0
-		}
 
-		ip2 := ImgPoint{pi2.X, pi2.Y, d2, v2.U / w2, v2.V / w2}
-		ip1 := ImgPoint{pi1.X, pi1.Y, d1, v1.U / w1, v1.V / w1}
-		ip3 := ImgPoint{pi3.X, pi3.Y, d3, v3.U / w3, v3.V / w3}
-		DrawTriangle2D2(img, ip1, ip2, ip3, shadedColor, depthBuffer, texture, intensity)
+	// clip before projecting, so vertices behind the camera do not remove the whole triangle
+	var clipped [maxClipVertices]clipVertex
+	polygon := frustum.clipTriangle(v1, v2, v3, ao, &clipped)
+	if len(polygon) < 3 {
+		return nil, normal
 	}
+	points := out[:len(polygon)]
+	for i, c := range polygon {
+		points[i] = frustum.project(c, imageSize)
+	}
+	return points, normal
 }
 
 // interpolateX calculates the X coordinate for a given Y using linear interpolation.
@@ -416,6 +410,16 @@ func denormaliseCuboidUVs(tilemap *Tilemap, uvs [][4][2]float64) [][4][2]float64
 }
 
 func CreateCuboidUVs(u, v, du, dv float64, texture string, scene *Scene) [][4][2]float64 {
+	x0, y0, x1, y1 := cuboidUVArea(u, v, du, dv, texture, scene)
+	uvs := make([][4][2]float64, len(cuboidFaceDirections))
+	for face := range uvs {
+		uvs[face] = cuboidFaceUVs(face, x0, y0, x1, y1)
+	}
+	return uvs
+}
+
+// cuboidUVArea returns the corners of the area of the texture from u, v of size du, dv pixels
+func cuboidUVArea(u, v, du, dv float64, texture string, scene *Scene) (float64, float64, float64, float64) {
 	k := 0.01 // prevent floating point error
 	u += k
 	v += k
@@ -429,16 +433,21 @@ func CreateCuboidUVs(u, v, du, dv float64, texture string, scene *Scene) [][4][2
 	// x0, y0, x1, y1 := meta.U*w, meta.V*h, (meta.U+meta.Width)*w, (meta.V+meta.Height)*h
 	x0, y0 := (meta.U + u/w), (meta.V + v/h)
 	x1, y1 := x0+(du/w), y0+(dv/h)
+	return x0, y0, x1, y1
+}
 
-	uvs := [][4][2]float64{
-		{{x1, y1}, {x1, y0}, {x0, y0}, {x0, y1}}, // Front face
-		{{x1, y1}, {x0, y1}, {x0, y0}, {x1, y0}}, // Back face
-		{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}, // Top face
-		{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}, // Bottom face
-		{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}, // Left face
-		{{x1, y1}, {x1, y0}, {x0, y0}, {x0, y1}}, // Right face
+// cuboidFaceUVs returns the uvs of one face of a cuboid textured with the area from x0, y0 to x1, y1
+func cuboidFaceUVs(face int, x0, y0, x1, y1 float64) [4][2]float64 {
+	switch face {
+	case 0: // Front face
+		return [4][2]float64{{x1, y1}, {x1, y0}, {x0, y0}, {x0, y1}}
+	case 1: // Back face
+		return [4][2]float64{{x1, y1}, {x0, y1}, {x0, y0}, {x1, y0}}
+	case 5: // Right face
+		return [4][2]float64{{x1, y1}, {x1, y0}, {x0, y0}, {x0, y1}}
+	default: // Top, Bottom and Left faces
+		return [4][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
 	}
-	return uvs
 }
 
 func DrawFilledCuboid(
@@ -449,6 +458,16 @@ func DrawFilledCuboid(
 	tilemap *Tilemap,
 	facesToRender *[]int,
 ) {
+	forEachCuboidTriangle(cuboid, tilemap, facesToRender, func(face int, v1, v2, v3 Vertex) {
+		DrawTriangle3D(v1, v2, v3, camera, img, cuboid.Color, depthBuffer, &tilemap.Image)
+	})
+}
+
+// the direction each face of a cuboid faces
+var cuboidFaceDirections = [6]Direction{Back, Front, Down, Up, Left, Right} // inconsistent direction order
+
+// forEachCuboidTriangle calls drawTriangle with the two triangles of each face to render
+func forEachCuboidTriangle(cuboid Cuboid, tilemap *Tilemap, facesToRender *[]int, drawTriangle func(face int, v1, v2, v3 Vertex)) {
 	// vertices := [...]Point3D{
 	// 	{min.X, min.Y, min.Z}, // 0: Left-bottom-front
 	// 	{max.X, min.Y, min.Z}, // 1: Right-bottom-front
@@ -486,21 +505,17 @@ func DrawFilledCuboid(
 		// uvs := createCuboidUVs(tilemap, cuboid.textures[i])
 		face := &faces[i]
 		uv := &uvs[i]
-		DrawTriangle3D(
+		drawTriangle(
+			i,
 			Vertex{cuboid.vertices[face[0]], uv[0][0], uv[0][1]},
 			Vertex{cuboid.vertices[face[1]], uv[1][0], uv[1][1]},
 			Vertex{cuboid.vertices[face[2]], uv[2][0], uv[2][1]},
-			camera,
-			img, cuboid.Color, depthBuffer,
-			&tilemap.Image,
 		)
-		DrawTriangle3D(
+		drawTriangle(
+			i,
 			Vertex{cuboid.vertices[face[0]], uv[0][0], uv[0][1]},
 			Vertex{cuboid.vertices[face[2]], uv[2][0], uv[2][1]},
 			Vertex{cuboid.vertices[face[3]], uv[3][0], uv[3][1]},
-			camera,
-			img, cuboid.Color, depthBuffer,
-			&tilemap.Image,
 		)
 	}
 }
@@ -576,26 +591,28 @@ type Vertex2 struct {
 	U  float64
 	V  float64
 	TZ float64
+	AO float64
 }
 
 func f2i(x float64) int {
 	return int(math.Round(x))
 }
 
-func getUVColor(u, v, depth float64, texture *image.RGBA) color.RGBA {
+// getUVColor returns the texture's colour at u, v in the mip level, darkened by shade
+func getUVColor(u, v, depth float64, texture *mipTexture, level int, shade float64) color.RGBA {
 	if DebugUV {
 		r, g, b := HSVToRGB(float64(int(depth*80.0)%360), 1.0, 1.0)
-		return color.RGBA{r, g, b, 255}
+		return ShadeColor(color.RGBA{r, g, b, 255}, shade)
 		return color.RGBA{100 + uint8(u*255)%50, 100 + uint8(v*255)%50, 100, 255}
 		return color.RGBA{uint8(u * 255), uint8(v * 255), 0, 255}
 	} else {
 		// tx := float64(texture.Bounds().Dx())
 		// ty := float64(texture.Bounds().Dy())
-		return texture.RGBAAt(int(u), int(v))
+		return ShadeColor(texture.sample(u, v, level), shade)
 	}
 }
 
-func renderFlatBottomTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, depthBuffer *DepthBuffer, shade float64) {
+func renderFlatBottomTriangle(img *image.RGBA, texture *mipTexture, v [3]Vertex2, grad *texGradients, depthBuffer *DepthBuffer, shade float64, blend bool, clip image.Rectangle) {
 	//texSize := Point2D{float64(texture.Bounds().Dx()), float64(texture.Bounds().Dy())}
 	imageSize := Int_2D{img.Bounds().Dx(), img.Bounds().Dy()}
 	// assumes vertices are already ordered such that: v0.Y < v1.Y = v2.Y
@@ -620,9 +637,9 @@ func renderFlatBottomTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2
 
 	dy := v[1].Y - v[0].Y
 	for y := v[0].Y; y <= v[1].Y; y++ {
-		if y < 0 {
+		if y < clip.Min.Y {
 			continue
-		} else if y >= imageSize.Y-1 {
+		} else if y >= clip.Max.Y {
 			break
 		}
 		ddy := float64(y - v[0].Y)
@@ -642,13 +659,16 @@ func renderFlatBottomTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2
 		w01 := ty*(v[1].TZ-v[0].TZ) + v[0].TZ
 		w02 := ty*(v[2].TZ-v[0].TZ) + v[0].TZ
 
+		a01 := ty*(v[1].AO-v[0].AO) + v[0].AO
+		a02 := ty*(v[2].AO-v[0].AO) + v[0].AO
+
 		// w01 := ty*(w[1]-w[0]) + w[0]
 		// w02 := ty*(w[2]-w[0]) + w[0]
 		// fmt.Println(" ", y, d01, d02)
 		for x := x01; x <= x02; x++ {
-			if x < 0 {
+			if x < clip.Min.X {
 				continue
-			} else if x >= imageSize.X-1 {
+			} else if x >= clip.Max.X {
 				break
 			}
 			t := float64(x-x01) / float64(x02-x01)
@@ -675,6 +695,7 @@ func renderFlatBottomTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2
 
 			u = u / ww
 			vv = vv / ww
+			ao := (a01 + t*(a02-a01)) / ww
 
 			dbi := y*int(imageSize.X) + x
 			if dbi > len(*depthBuffer) {
@@ -682,16 +703,16 @@ func renderFlatBottomTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2
 				panic("Index out of range")
 			}
 			if depth < (*depthBuffer)[dbi] {
-				(*depthBuffer)[dbi] = depth
-				clr := getUVColor(u, vv, depth, texture)
-				clr = ShadeColor(clr, shade)
-				// img.Set(x, y, clr)
-
-				// currentColor := img.RGBAAt(x, y)
-				// newColor := CombineColors(clr, currentColor)
-				// img.SetRGBA(x, y, clr)
+				level := texture.level(grad, u, vv, ww)
+				clr := getUVColor(u, vv, depth, texture, level, shade*ao)
 				ii := (imageSize.X*y + x) * 4
 				s := img.Pix[ii : ii+3] // Small cap improves performance, see https://golang.org/issue/27857
+				if blend {
+					// translucent pixels are depth tested, but do not hide what is drawn behind them later
+					clr = CombineColors(clr, color.RGBA{s[0], s[1], s[2], 255})
+				} else {
+					(*depthBuffer)[dbi] = depth
+				}
 				s[0] = clr.R
 				s[1] = clr.G
 				s[2] = clr.B
@@ -702,7 +723,7 @@ func renderFlatBottomTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2
 	// DrawLine(img, Int_2D{v[1].X, v[1].Y}, Int_2D{v[2].X, v[2].Y}, White.ToRGBA())
 }
 
-func renderFlatTopTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, depthBuffer *DepthBuffer, shade float64) {
+func renderFlatTopTriangle(img *image.RGBA, texture *mipTexture, v [3]Vertex2, grad *texGradients, depthBuffer *DepthBuffer, shade float64, blend bool, clip image.Rectangle) {
 	// texSize := Point2D{float64(texture.Bounds().Dx()), float64(texture.Bounds().Dy())}
 	imageSize := Int_2D{img.Bounds().Dx(), img.Bounds().Dy()}
 	// assumes vertices are already ordered such that: v0.Y = v1.Y < v2.Y
@@ -726,9 +747,9 @@ func renderFlatTopTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, d
 	// }
 
 	for y := v[0].Y; y <= v[2].Y; y++ {
-		if y < 0 {
+		if y < clip.Min.Y {
 			continue
-		} else if y >= imageSize.Y-1 {
+		} else if y >= clip.Max.Y {
 			break
 		}
 		dy := float64(y - v[2].Y)
@@ -749,15 +770,18 @@ func renderFlatTopTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, d
 		w02 := ty*(v[2].TZ-v[0].TZ) + v[0].TZ
 		w12 := ty*(v[2].TZ-v[1].TZ) + v[1].TZ
 
+		a02 := ty*(v[2].AO-v[0].AO) + v[0].AO
+		a12 := ty*(v[2].AO-v[1].AO) + v[1].AO
+
 		// w02 := ty*(w[2]-w[0]) + w[0]
 		// w12 := ty*(w[2]-w[1]) + w[1]
 
 		// fmt.Println(y, ty, v02, v12)
 
 		for x := x02; x <= x12; x++ {
-			if x < 0 {
+			if x < clip.Min.X {
 				continue
-			} else if x >= imageSize.X-1 {
+			} else if x >= clip.Max.X {
 				break
 			}
 
@@ -773,6 +797,7 @@ func renderFlatTopTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, d
 
 			u = u / ww
 			vv = vv / ww
+			ao := (a02 + t*(a12-a02)) / ww
 
 			// invDepth1 := 1.0 / d12
 			// invDepth2 := 1.0 / d02
@@ -794,14 +819,16 @@ func renderFlatTopTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, d
 				panic("Index out of range")
 			}
 			if depth < (*depthBuffer)[dbi] {
-				(*depthBuffer)[dbi] = depth
-				clr := getUVColor(u, vv, depth, texture)
-				clr = ShadeColor(clr, shade)
-				// currentColor := img.RGBAAt(x, y)
-				// newColor := CombineColors(clr, currentColor)
-				// img.SetRGBA(x, y, clr)
+				level := texture.level(grad, u, vv, ww)
+				clr := getUVColor(u, vv, depth, texture, level, shade*ao)
 				ii := (imageSize.X*y + x) * 4
 				s := img.Pix[ii : ii+3] // Small cap improves performance, see https://golang.org/issue/27857
+				if blend {
+					// translucent pixels are depth tested, but do not hide what is drawn behind them later
+					clr = CombineColors(clr, color.RGBA{s[0], s[1], s[2], 255})
+				} else {
+					(*depthBuffer)[dbi] = depth
+				}
 				s[0] = clr.R
 				s[1] = clr.G
 				s[2] = clr.B
@@ -811,12 +838,23 @@ func renderFlatTopTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, d
 }
 
 func DrawTriangle2D2(img *image.RGBA, p1, p2, p3 ImgPoint, col color.RGBA,
-	depthBuffer *DepthBuffer, texture *image.RGBA, shade float64,
+	depthBuffer *DepthBuffer, texture *mipTexture, shade float64, blend bool,
 ) {
-	v := [3]Vertex2{
-		{p1.X, p1.Y, p1.Z, p1.U, p1.V, 1.0 / p1.Z},
-		{p2.X, p2.Y, p2.Z, p2.U, p2.V, 1.0 / p2.Z},
-		{p3.X, p3.Y, p3.Z, p3.U, p3.V, 1.0 / p3.Z},
+	v := toVertex2s(p1, p2, p3)
+	renderTriangle(img, texture, v, depthBuffer, shade, blend, renderClip(img))
+	if drawTriangleWire {
+		DrawLine(img, Int_2D{p1.X, p1.Y}, Int_2D{p2.X, p2.Y}, White.ToRGBA())
+		DrawLine(img, Int_2D{p1.X, p1.Y}, Int_2D{p3.X, p3.Y}, White.ToRGBA())
+		DrawLine(img, Int_2D{p3.X, p3.Y}, Int_2D{p2.X, p2.Y}, White.ToRGBA())
+	}
+
+}
+
+func toVertex2s(p1, p2, p3 ImgPoint) [3]Vertex2 {
+	return [3]Vertex2{
+		{p1.X, p1.Y, p1.Z, p1.U, p1.V, 1.0 / p1.Z, p1.AO},
+		{p2.X, p2.Y, p2.Z, p2.U, p2.V, 1.0 / p2.Z, p2.AO},
+		{p3.X, p3.Y, p3.Z, p3.U, p3.V, 1.0 / p3.Z, p3.AO},
 	}
 	// if TexturePerspective {
 	// 	v = [3]Vertex2{
@@ -831,16 +869,15 @@ func DrawTriangle2D2(img *image.RGBA, p1, p2, p3 ImgPoint, col color.RGBA,
 	// 		{p3.X, p3.Y, p3.Z, p3.U, p3.V, 1.0},
 	// 	}
 	// }
-	renderTriangle(img, texture, v, depthBuffer, shade)
-	if drawTriangleWire {
-		DrawLine(img, Int_2D{p1.X, p1.Y}, Int_2D{p2.X, p2.Y}, White.ToRGBA())
-		DrawLine(img, Int_2D{p1.X, p1.Y}, Int_2D{p3.X, p3.Y}, White.ToRGBA())
-		DrawLine(img, Int_2D{p3.X, p3.Y}, Int_2D{p2.X, p2.Y}, White.ToRGBA())
-	}
+}
 
+// renderClip is the area of img that triangles are drawn to, the last row and column are not drawn
+func renderClip(img *image.RGBA) image.Rectangle {
+	return image.Rect(0, 0, img.Bounds().Dx()-1, img.Bounds().Dy()-1)
 }
 
-func renderTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, depthBuffer *DepthBuffer, shade float64) {
+// renderTriangle draws the pixels of the triangle inside clip
+func renderTriangle(img *image.RGBA, texture *mipTexture, v [3]Vertex2, depthBuffer *DepthBuffer, shade float64, blend bool, clip image.Rectangle) {
 	// sort vertices so v0.Y <= v1.Y <= v2.Y
 	if v[0].Y > v[1].Y {
 		v[0], v[1] = v[1], v[0]
@@ -851,11 +888,14 @@ func renderTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, depthBuf
 	if v[0].Y > v[1].Y {
 		v[0], v[1] = v[1], v[0]
 	}
+	// the halves of a split triangle share the gradients of the whole triangle, as the split
+	// vertex is rounded to a pixel, so both halves choose the same mip levels
+	grad := triangleTexGradients(v)
 
 	if v[1].Y == v[2].Y {
-		renderFlatBottomTriangle(img, texture, v, depthBuffer, shade)
+		renderFlatBottomTriangle(img, texture, v, &grad, depthBuffer, shade, blend, clip)
 	} else if v[0].Y == v[1].Y {
-		renderFlatTopTriangle(img, texture, v, depthBuffer, shade)
+		renderFlatTopTriangle(img, texture, v, &grad, depthBuffer, shade, blend, clip)
 	} else {
 		t := float64(v[1].Y-v[0].Y) / float64(v[2].Y-v[0].Y)
 		x := v[0].X + int(t*float64(v[2].X-v[0].X))
@@ -863,9 +903,10 @@ func renderTriangle(img *image.RGBA, texture *image.RGBA, v [3]Vertex2, depthBuf
 		a := v[0].U + t*(v[2].U-v[0].U)
 		b := v[0].V + t*(v[2].V-v[0].V)
 		tz := v[0].TZ + t*(v[2].TZ-v[0].TZ)
+		ao := v[0].AO + t*(v[2].AO-v[0].AO)
 		// fmt.Println(z, v[0].Z, v[2].Z)
-		renderFlatBottomTriangle(img, texture, [3]Vertex2{v[0], {x, v[1].Y, z, a, b, tz}, v[1]}, depthBuffer, shade)
-		renderFlatTopTriangle(img, texture, [3]Vertex2{{x, v[1].Y, z, a, b, tz}, v[1], v[2]}, depthBuffer, shade)
+		renderFlatBottomTriangle(img, texture, [3]Vertex2{v[0], {x, v[1].Y, z, a, b, tz, ao}, v[1]}, &grad, depthBuffer, shade, blend, clip)
+		renderFlatTopTriangle(img, texture, [3]Vertex2{{x, v[1].Y, z, a, b, tz, ao}, v[1], v[2]}, &grad, depthBuffer, shade, blend, clip)
 	}
 }
 
@@ -880,44 +921,36 @@ func Convert1DTo3D(i int) Point3D {
 }
 
 func DrawObjects(scene *Scene, img *image.RGBA, depthBuffer *DepthBuffer) {
-	// {0, 3, 2, 1}, // Front face
-	// {4, 5, 6, 7}, // Back face
-	// {0, 1, 5, 4}, // Top face
-	// {2, 3, 7, 6}, // Bottom face
-	// {7, 3, 0, 4}, // Left face
-	// {1, 2, 6, 5}, // Right face
-	var Directions = [6]Direction{Back, Front, Down, Up, Left, Right} // inconsistent direction order
-	for i, block := range scene.World.Blocks {
-		rb, isRenderable := block.(WireRenderBlock)
-
-		if isRenderable {
-			// if a block and is neighbour are opaque on their shared face then dont render
-			var faces []int
-			position := Convert1DTo3D(i)
-			opaqueBlock, isOpaqueBlock := block.(OpaqueBlock)
-			if skipAdjacentFaces && isOpaqueBlock {
-				for face, direction := range Directions {
-					if !opaqueBlock.IsOpaqueInDirection(direction) {
-						continue
-					}
-					np := position.ToVec3().Move(direction)
-					neighbour := scene.World.GetBlock(np)
-					nOpaqueBlock, nIsOpaqueBlock := neighbour.(OpaqueBlock)
-					if !nIsOpaqueBlock || !nOpaqueBlock.IsOpaqueInDirection(direction.GetOppositeDirection()) {
-						faces = append(faces, face)
-					}
-				}
-			} else {
-				faces = []int{0, 1, 2, 3, 4, 5}
-			}
-
-			for _, c := range rb.ToCuboids(scene) {
-				// generating a new cuboid is bad. mutate or move vertices dynamically inside function
-				movedCuboid := c.Move(position)
-				DrawFilledCuboid(movedCuboid, scene.Camera, img, depthBuffer, &scene.Tilemap, &faces)
+	changed := scene.worldChanges.Update(&scene.World)
+	scene.meshCache.Update(scene, changed)
+	scene.LightMap.Update(&scene.World, changed)
+	texture := newMipTexture(&scene.Tilemap, scene.TextureSampler)
+	frustum := scene.Camera.Frustum()
+	isParallel := scene.RenderWorkers > 1
+	if isParallel {
+		scene.rasterizer.Reset(img.Bounds().Size())
+	}
+	drawMeshTriangle := func(t *meshTriangle, blend bool) {
+		if isParallel {
+			scene.rasterizer.AddTriangle(t.v[0], t.v[1], t.v[2], t.ao, frustum, texture, blend, &scene.LightModel, t.lightLevel(&scene.LightMap))
+		} else {
+			drawTriangle3D(t.v[0], t.v[1], t.v[2], t.ao, frustum, img, t.color, depthBuffer, texture, blend, &scene.LightModel, t.lightLevel(&scene.LightMap))
+		}
+	}
 
-			}
+	scene.translucent.Reset()
+	scene.Culling = scene.meshCache.forEachVisibleTriangle(frustum, func(t *meshTriangle) {
+		if t.translucent {
+			scene.translucent.Add(t, scene.Camera.Position)
+		} else {
+			drawMeshTriangle(t, false)
 		}
+	})
+	for _, tt := range scene.translucent.Sorted() {
+		drawMeshTriangle(tt.t, true)
+	}
+	if isParallel {
+		scene.rasterizer.Rasterize(img, depthBuffer, scene.RenderWorkers)
 	}
 
 	// Calculate aspect ratio based on the image dimensions
@@ -991,21 +1024,107 @@ func DrawDebugInformation(scene *Scene, img *image.RGBA) {
 		), Cyan.ToRGBA(), scene.FontFace)
 
 	DrawText(img, 4, fontSize*2,
-		fmt.Sprintf("F/S: %d, I/S %d, S: %s",
+		fmt.Sprintf("F/S: %d/%d, I/S %d, S: %s, M: %s, Sp: %s, L: %s, T: %s",
 			scene.RecordedFramesPerSecond,
+			scene.FramesPerSecond,
 			scene.RecordedStepsPerSecond,
 			scene.GameState.String(),
+			scene.SimulationMode.String(),
+			scene.SpeedString(),
+			scene.LightModel.String(),
+			scene.TextureSampler.String(),
 		), Cyan.ToRGBA(), scene.FontFace)
 
 	DrawText(img, 4, fontSize*3, fmt.Sprintf(
-		"XYZ: %.1f %.1f %.1f, Rot: %.1f %.1f %.1f",
+		"XYZ: %.1f %.1f %.1f, Rot: %.1f %.1f %.1f, B: %d/%d, C: %d",
 		scene.Camera.Position.X,
 		scene.Camera.Position.Y,
 		scene.Camera.Position.Z,
 		RadToDeg(scene.Camera.Rotation.X),
 		RadToDeg(scene.Camera.Rotation.Y),
 		RadToDeg(scene.Camera.Rotation.Z),
+		scene.Culling.BlocksDrawn,
+		scene.Culling.BlocksCulled,
+		scene.Culling.ChunksCulled,
+	), Cyan.ToRGBA(), scene.FontFace)
+
+	period := "none"
+	if d := scene.PeriodDetector; d.IsStable {
+		period = fmt.Sprintf("stable from I: %d", d.CycleStart)
+	} else if d.Period > 0 {
+		period = fmt.Sprintf("%d from I: %d", d.Period, d.CycleStart)
+	}
+	region := "world"
+	if r := scene.PeriodDetector.Region; r != nil {
+		region = fmt.Sprintf("%v-%v", r.Min, r.Max)
+	}
+	numTicks, numUndone := scene.History.NumTicks()
+	DrawText(img, 4, fontSize*4, fmt.Sprintf(
+		"Period: %s, Region: %s, H: -%d/+%d", period, region, numTicks, numUndone,
+	), Cyan.ToRGBA(), scene.FontFace)
+
+	if h := scene.BreakpointHit; h != nil {
+		DrawText(img, 4, fontSize*6, fmt.Sprintf("Break %v", h), Red.ToRGBA(), scene.FontFace)
+	}
+
+	if o := scene.SubUpdateOscillation; o != nil {
+		cycle := "no cycle"
+		if o.CycleLength > 0 {
+			cycle = fmt.Sprintf("cycle %d", o.CycleLength)
+		}
+		DrawText(img, 4, fontSize*5, fmt.Sprintf(
+			"Unstable at I: %d, %s, %d blocks",
+			o.Iteration,
+			cycle,
+			len(o.Positions),
+		), Red.ToRGBA(), scene.FontFace)
+	}
+
+	if scene.ShowFrameTimeGraph {
+		DrawFrameTimeGraph(scene, img)
+	}
+}
+
+// DrawFrameTimeGraph draws the duration of recent renders as bars, with the start jitter stacked on top,
+// in the bottom left corner. The line is the target frame time.
+func DrawFrameTimeGraph(scene *Scene, img *image.RGBA) {
+	render, update := scene.EventTimings["Render"], scene.EventTimings["Update"]
+	if render == nil {
+		return
+	}
+	fontSize := Int26_6ToInt(scene.FontFace.Metrics().Height)
+	graphHeight := 64
+	bottom := img.Bounds().Dy() - 1
+	target := ratePerSecondToDuration(scene.FramesPerSecond).Nanoseconds()
+	// the target frame time is drawn at half the height of the graph
+	toHeight := func(d int64) int {
+		return min(int(d*int64(graphHeight)/(2*target)), graphHeight)
+	}
+
+	jitters := render.Jitters()
+	for x, duration := range render.Durations() {
+		if x >= img.Bounds().Dx() {
+			break
+		}
+		durationHeight := toHeight(duration)
+		jitterHeight := toHeight(duration + max(jitters[x], 0))
+		DrawLine(img, Int_2D{x, bottom}, Int_2D{x, bottom - durationHeight}, Cyan.ToRGBA())
+		if jitterHeight > durationHeight {
+			DrawLine(img, Int_2D{x, bottom - durationHeight}, Int_2D{x, bottom - jitterHeight}, Orange.ToRGBA())
+		}
+	}
+	targetY := bottom - graphHeight/2
+	DrawLine(img, Int_2D{0, targetY}, Int_2D{timingHistoryLength - 1, targetY}, Red.ToRGBA())
+
+	// p50/p95/p99/max
+	DrawText(img, 4, bottom-graphHeight-fontSize, fmt.Sprintf(
+		"Render %v, jitter %v", render.DurationSummary(), render.JitterSummary(),
 	), Cyan.ToRGBA(), scene.FontFace)
+	if update != nil {
+		DrawText(img, 4, bottom-graphHeight-fontSize*2, fmt.Sprintf(
+			"Update %v, jitter %v", update.DurationSummary(), update.JitterSummary(),
+		), Cyan.ToRGBA(), scene.FontFace)
+	}
 }
 
 func DrawTestTriangles(scene *Scene, img *image.RGBA, depthBuffer *DepthBuffer) {
@@ -1445,6 +1564,12 @@ func DrawScene(scene *Scene, img *image.RGBA, depthBuffer *DepthBuffer) {
 		DrawWireCube(scene, img, selectedPos.ToPoint3D())
 	}
 
+	if scene.SubUpdateOscillation != nil {
+		for _, p := range scene.SubUpdateOscillation.Positions {
+			DrawWireCube(scene, img, p.ToPoint3D())
+		}
+	}
+
 	drawCrossHair(img)
 	DrawDebugInformation(scene, img)
 }
@@ -4,8 +4,13 @@
 package core
 
 import (
+	"bytes"
+	"fmt"
 	"image"
 	"image/color"
+	"math"
+	"runtime"
+	"slices"
 	"testing"
 )
 
@@ -59,3 +64,678 @@ func TestDDA3D(t *testing.T) {
 
 	SaveImage(img, CreateProjectRelativePath("output/TestDDA3D.png"))
 }
+
+// createTestRenderScene creates a scene of the demo world with a generated tilemap, as the assets are not loaded
+func createTestRenderScene(width, height int) Scene {
+	scene := Scene{}
+	CreateWorld(&scene.World)
+	tilemap := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			tilemap.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), uint8((x ^ y) * 4), 255})
+		}
+	}
+	scene.Tilemap = Tilemap{Image: *tilemap}
+	blockModels, err := LoadBlockModels()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load block models: %v", err))
+	}
+	scene.BlockModels = blockModels
+	scene.Camera = Camera{
+		Position:    Point3D{X: 3.5, Y: 5.5, Z: -4},
+		Rotation:    Point3D{X: DegToRad(-20), Y: DegToRad(-20), Z: 0},
+		FOV:         90.0,
+		AspectRatio: float64(height) / float64(width),
+		Near:        0.1,
+		Far:         100.0,
+	}
+	scene.LightModel = DefaultLightModel()
+	return scene
+}
+
+func drawTestScene(scene *Scene, img *image.RGBA, depthBuffer *DepthBuffer) {
+	clearDepthBuffer(depthBuffer)
+	clearSceneImage(img)
+	DrawObjects(scene, img, depthBuffer)
+}
+
+func TestTileRasterizerMatchesSerial(t *testing.T) {
+	// not a multiple of the tile size, so the edge tiles are partial
+	width, height := 300, 200
+	scene := createTestRenderScene(width, height)
+
+	scene.RenderWorkers = 1
+	expected := image.NewRGBA(image.Rect(0, 0, width, height))
+	expectedDepth := make(DepthBuffer, width*height)
+	drawTestScene(&scene, expected, &expectedDepth)
+
+	background := expected.RGBAAt(width-1, height-1)
+	numDrawn := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if expected.RGBAAt(x, y) != background {
+				numDrawn++
+			}
+		}
+	}
+	if numDrawn < width*height/4 {
+		t.Fatalf("Expected the world to cover the image, only %d pixels were drawn", numDrawn)
+	}
+
+	for _, workers := range []int{2, 3, 8} {
+		scene.RenderWorkers = workers
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		depthBuffer := make(DepthBuffer, width*height)
+		// draw twice so the rasterizer is reused
+		drawTestScene(&scene, img, &depthBuffer)
+		drawTestScene(&scene, img, &depthBuffer)
+		if !bytes.Equal(img.Pix, expected.Pix) {
+			t.Errorf("Expected the image drawn by %d workers to match the serial image", workers)
+		}
+		if !slices.Equal(depthBuffer, expectedDepth) {
+			t.Errorf("Expected the depth drawn by %d workers to match the serial depth", workers)
+		}
+	}
+}
+
+func BenchmarkDrawObjects(b *testing.B) {
+	width, height := 512, 512
+	for workers := 1; workers <= max(8, runtime.NumCPU()); workers *= 2 {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			scene := createTestRenderScene(width, height)
+			scene.RenderWorkers = workers
+			img := image.NewRGBA(image.Rect(0, 0, width, height))
+			depthBuffer := make(DepthBuffer, width*height)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				drawTestScene(&scene, img, &depthBuffer)
+			}
+		})
+	}
+}
+
+func TestMeshCacheRebuildsChangedChunks(t *testing.T) {
+	width, height := 200, 200
+	scene := createTestRenderScene(width, height)
+	scene.RenderWorkers = 1
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	depthBuffer := make(DepthBuffer, width*height)
+
+	drawTestScene(&scene, img, &depthBuffer)
+	if scene.meshCache.NumRebuilt != numMeshChunks {
+		t.Errorf("Expected all %d chunks to be built on the first frame, got %d", numMeshChunks, scene.meshCache.NumRebuilt)
+	}
+	drawTestScene(&scene, img, &depthBuffer)
+	if scene.meshCache.NumRebuilt != 0 {
+		t.Errorf("Expected no chunks to be rebuilt when nothing changed, got %d", scene.meshCache.NumRebuilt)
+	}
+
+	// z = 5 is in the middle of a chunk, only the chunk behind is also a neighbour
+	scene.World.SetBlock(Vec3{X: 4, Y: 1, Z: 5}, WoolBlock{Red, None})
+	drawTestScene(&scene, img, &depthBuffer)
+	if scene.meshCache.NumRebuilt != 2 {
+		t.Errorf("Expected 2 chunks to be rebuilt, got %d", scene.meshCache.NumRebuilt)
+	}
+
+	// the cached image matches an image drawn from an empty cache
+	fresh := scene
+	fresh.meshCache = MeshCache{}
+	expected := image.NewRGBA(image.Rect(0, 0, width, height))
+	expectedDepth := make(DepthBuffer, width*height)
+	drawTestScene(&fresh, expected, &expectedDepth)
+	if !bytes.Equal(img.Pix, expected.Pix) || !slices.Equal(depthBuffer, expectedDepth) {
+		t.Errorf("Expected the cached image to match the image drawn from an empty cache")
+	}
+}
+
+func TestFrustumCulling(t *testing.T) {
+	width, height := 200, 100
+	scene := createTestRenderScene(width, height)
+	scene.RenderWorkers = 1
+	// in the middle of the world, so blocks are on every side of the camera
+	scene.Camera.Position = Point3D{X: 8, Y: 3, Z: 8}
+	frustum := scene.Camera.Frustum()
+
+	ahead := scene.Camera.Position.Add(Point3D{X: 0, Y: 0, Z: 5}.RotateY(-scene.Camera.Rotation.Y))
+	behind := scene.Camera.Position.Add(Point3D{X: 0, Y: 0, Z: -5}.RotateY(-scene.Camera.Rotation.Y))
+	for _, c := range []struct {
+		center   Point3D
+		expected bool
+	}{{ahead, true}, {behind, false}} {
+		if frustum.IntersectsBox(c.center.Subtract(Point3DFromScalar(0.5)), c.center.Add(Point3DFromScalar(0.5))) != c.expected {
+			t.Errorf("Expected a box at %v to intersect the frustum: %v", c.center, c.expected)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	depthBuffer := make(DepthBuffer, width*height)
+	drawTestScene(&scene, img, &depthBuffer)
+	stats := scene.Culling
+	numBlocks := 0
+	for _, chunk := range scene.meshCache.chunks {
+		numBlocks += len(chunk.blocks)
+	}
+	if stats.BlocksDrawn == 0 || stats.BlocksCulled == 0 || stats.BlocksDrawn+stats.BlocksCulled != numBlocks {
+		t.Fatalf("Expected some of the %d blocks to be drawn and some culled, got %+v", numBlocks, stats)
+	}
+
+	// culling must not change the image, so no triangle of a culled block would have been drawn
+	imageSize := Point2D{X: float64(width), Y: float64(height)}
+	for _, chunk := range scene.meshCache.chunks {
+		for _, b := range chunk.blocks {
+			if frustum.IntersectsBox(b.min, b.max) {
+				continue
+			}
+			for _, tri := range chunk.triangles[b.first:b.end] {
+				var points [maxClipVertices]ImgPoint
+				if polygon, _ := projectTriangle(tri.v[0], tri.v[1], tri.v[2], tri.ao, frustum, imageSize, &points); len(polygon) > 0 {
+					t.Fatalf("Expected the triangles of a culled block at %v to be off screen", b.min)
+				}
+			}
+		}
+	}
+}
+
+func TestNearPlaneClipping(t *testing.T) {
+	width, height := 100, 100
+	camera := Camera{FOV: 90.0, AspectRatio: 1.0, Near: 0.1, Far: 100.0}
+	frustum := camera.Frustum()
+
+	// a wall beside the camera, from behind it to in front of it
+	wall := [3]Vertex{
+		{Point3D{X: -0.5, Y: -1, Z: -2}, 0, 0},
+		{Point3D{X: -0.5, Y: 1, Z: 3}, 15, 15},
+		{Point3D{X: -0.5, Y: -1, Z: 3}, 15, 0},
+	}
+	var clipped [maxClipVertices]clipVertex
+	polygon := frustum.clipTriangle(wall[0], wall[1], wall[2], noOcclusion, &clipped)
+	if len(polygon) < 3 {
+		t.Fatalf("Expected the wall to be clipped to a polygon, got %d vertices", len(polygon))
+	}
+	for _, c := range polygon {
+		for plane := 0; plane < numFrustumPlanes; plane++ {
+			if d := frustum.planeDistance(plane, c.p); d < -1e-9 {
+				t.Errorf("Expected %v to be inside plane %d, got distance %g", c.p, plane, d)
+			}
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	depthBuffer := make(DepthBuffer, width*height)
+	clearDepthBuffer(&depthBuffer)
+	texture := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range texture.Pix {
+		texture.Pix[i] = 255
+	}
+	DrawTriangle3D(wall[0], wall[1], wall[2], camera, img, White.ToRGBA(), &depthBuffer, texture)
+	numDrawn := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			numDrawn++
+		}
+	}
+	if numDrawn < width*height/8 {
+		t.Errorf("Expected the visible part of the wall to be drawn, only %d pixels were drawn", numDrawn)
+	}
+
+	// a triangle inside the frustum is not changed
+	inside := [3]Vertex{
+		{Point3D{X: 0, Y: 0, Z: 2}, 0, 0},
+		{Point3D{X: 0.5, Y: 0.5, Z: 2}, 15, 15},
+		{Point3D{X: 0.5, Y: 0, Z: 2}, 15, 0},
+	}
+	polygon = frustum.clipTriangle(inside[0], inside[1], inside[2], noOcclusion, &clipped)
+	if len(polygon) != 3 {
+		t.Fatalf("Expected a triangle inside the frustum to keep its 3 vertices, got %d", len(polygon))
+	}
+	for i, c := range polygon {
+		if c.p != inside[i].Position || c.u != inside[i].U || c.v != inside[i].V {
+			t.Errorf("Expected vertex %d to be unchanged, got %+v", i, c)
+		}
+	}
+}
+
+func TestLightModel(t *testing.T) {
+	model := DefaultLightModel()
+	if model.Mode != SunAndBlockLight {
+		t.Errorf("Expected the default mode to be %s, got %s", SunAndBlockLight, model.Mode)
+	}
+	towardsSun := model.SunDirection
+	awayFromSun := Point3D{}.Subtract(model.SunDirection)
+
+	tests := []struct {
+		mode       LightMode
+		normal     Point3D
+		blockLight int
+		want       float64
+	}{
+		{SunLight, towardsSun, 0, 1},
+		{SunLight, awayFromSun, 0, model.Ambient},
+		{SunLight, awayFromSun, MaxLightLevel, model.Ambient},
+		{BlockLight, awayFromSun, 0, minBlockLight},
+		{BlockLight, towardsSun, MaxLightLevel, 1},
+		{SunAndBlockLight, towardsSun, MaxLightLevel, 1},
+		{SunAndBlockLight, awayFromSun, 0, model.Ambient}, // unlit faces are not darkened
+		{SunAndBlockLight, awayFromSun, MaxLightLevel, 1},
+		{Unlit, awayFromSun, 0, 1},
+	}
+	for _, test := range tests {
+		model.Mode = test.mode
+		if got := model.Shade(test.normal, test.blockLight); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: Expected shade %v for normal %v and block light %d, got %v",
+				test.mode, test.want, test.normal, test.blockLight, got)
+		}
+	}
+
+	model.Mode = Unlit
+	model.NextMode()
+	if model.Mode != SunAndBlockLight {
+		t.Errorf("Expected the mode after %s to be %s, got %s", Unlit, SunAndBlockLight, model.Mode)
+	}
+	azimuth := model.sunAzimuth()
+	model.RotateSun(DegToRad(30))
+	if got := math.Mod(model.sunAzimuth()-azimuth+360, 360); math.Abs(got-30) > 1e-6 && math.Abs(got-330) > 1e-6 {
+		t.Errorf("Expected rotating the sun to turn it 30 degrees, turned %v", got)
+	}
+}
+
+func TestLampBrightensNearbyFaces(t *testing.T) {
+	model := DefaultLightModel()
+	wool := Vec3{X: 8, Y: 8, Z: 8}
+	lampPosition := wool.Move(Left).Move(Left)
+	// the wool's left face, lit by the light level of the position it faces
+	w := World{}
+	face := meshTriangle{lightIndices: [2]int{w.GetIndex(wool), w.GetIndex(wool.Move(Left))}}
+	normal := Point3D{-1, 0, 0}
+
+	shade := func(lamp Block) float64 {
+		w := World{}
+		w.SetBlock(wool, WoolBlock{White, None})
+		w.SetBlock(lampPosition, lamp)
+		l := LightMap{}
+		l.Update(&w, nil)
+		return model.Shade(normal, face.lightLevel(&l))
+	}
+	unlit := shade(RedstoneLamp{InputPowerType: None})
+	lit := shade(RedstoneLamp{InputPowerType: Strong})
+	if lit <= unlit {
+		t.Errorf("Expected a face next to a lit lamp to be brighter than without one, got %v and %v", lit, unlit)
+	}
+	if sun := model.Ambient + max(0, DotProduct(normal, model.SunDirection))*(1-model.Ambient); math.Abs(unlit-sun) > 1e-9 {
+		t.Errorf("Expected a face with no block light to keep its sun shade %v, got %v", sun, unlit)
+	}
+}
+
+func TestAmbientOcclusion(t *testing.T) {
+	w := World{}
+	floor := Vec3{X: 8, Y: 4, Z: 8}
+	w.SetBlock(floor, WoolBlock{White, None})
+	// a wall along the floor's left edge, and a block past the floor's front right corner
+	w.SetBlock(floor.Move(Up).Move(Left), WoolBlock{White, None})
+	w.SetBlock(floor.Move(Up).Move(Left).Move(Front), WoolBlock{White, None})
+	w.SetBlock(floor.Move(Up).Move(Right).Move(Front), WoolBlock{White, None})
+
+	top := floor.ToPoint3D().Add(Point3D{Y: 1})
+	for _, c := range []struct {
+		corner   Point3D
+		expected float64
+	}{
+		{top.Add(Point3D{X: 0, Z: 0}), aoBrightness[2]}, // against the wall
+		{top.Add(Point3D{X: 0, Z: 1}), aoBrightness[1]}, // against the wall and the block past its end
+		{top.Add(Point3D{X: 1, Z: 0}), aoBrightness[3]}, // open
+		{top.Add(Point3D{X: 1, Z: 1}), aoBrightness[2]}, // next to the corner block
+	} {
+		if ao := vertexAmbientOcclusion(&w, floor, Up, c.corner); ao != c.expected {
+			t.Errorf("Expected ambient occlusion %v at %v, got %v", c.expected, c.corner, ao)
+		}
+	}
+
+	// a corner between two walls is darkest
+	w.SetBlock(floor.Move(Up).Move(Back), WoolBlock{White, None})
+	if ao := vertexAmbientOcclusion(&w, floor, Up, top); ao != aoBrightness[0] {
+		t.Errorf("Expected ambient occlusion %v between two walls, got %v", aoBrightness[0], ao)
+	}
+
+	// the bottom face has nothing below it
+	if ao := vertexAmbientOcclusion(&w, floor, Down, floor.ToPoint3D()); ao != 1 {
+		t.Errorf("Expected no ambient occlusion below the floor, got %v", ao)
+	}
+
+	// turning ambient occlusion off does not darken any vertex
+	model := DefaultLightModel()
+	ao := [3]float64{aoBrightness[0], aoBrightness[1], 1}
+	if model.occlusion(ao) != ao {
+		t.Errorf("Expected the light model to use the vertices' ambient occlusion")
+	}
+	model.AmbientOcclusion = false
+	if model.occlusion(ao) != noOcclusion {
+		t.Errorf("Expected no ambient occlusion when it is off")
+	}
+}
+
+func TestTranslucentPass(t *testing.T) {
+	width, height := 160, 120
+	scene := createTestRenderScene(width, height)
+	// the torches' textures are in the right half of the tilemap, which is translucent
+	scene.Tilemap.Metas = map[string]TextureMeta{
+		"redstone_torch":     {U: 0.5, V: 0, Width: 0.25, Height: 0.25},
+		"redstone_torch_off": {U: 0.75, V: 0, Width: 0.25, Height: 0.25},
+	}
+	tilemap := &scene.Tilemap.Image
+	for y := 0; y < 64; y++ {
+		for x := 32; x < 64; x++ {
+			c := tilemap.RGBAAt(x, y)
+			c.A = 128
+			tilemap.SetRGBA(x, y, c)
+		}
+	}
+
+	scene.RenderWorkers = 1
+	expected := image.NewRGBA(image.Rect(0, 0, width, height))
+	expectedDepth := make(DepthBuffer, width*height)
+	drawTestScene(&scene, expected, &expectedDepth)
+	numOpaque := 0
+	for _, chunk := range scene.meshCache.chunks {
+		for _, tri := range chunk.triangles {
+			if !tri.translucent {
+				numOpaque++
+			}
+		}
+	}
+	sorted := scene.translucent.Sorted()
+	if len(sorted) == 0 || numOpaque == 0 {
+		t.Fatalf("Expected only the torches' triangles to be translucent, got %d translucent and %d opaque",
+			len(sorted), numOpaque)
+	}
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].distance > sorted[i-1].distance {
+			t.Fatalf("Expected translucent triangles to be sorted back to front, %v is before %v",
+				sorted[i-1].distance, sorted[i].distance)
+		}
+	}
+
+	scene.RenderWorkers = 3
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	depthBuffer := make(DepthBuffer, width*height)
+	drawTestScene(&scene, img, &depthBuffer)
+	if !bytes.Equal(img.Pix, expected.Pix) || !slices.Equal(depthBuffer, expectedDepth) {
+		t.Errorf("Expected blending in parallel to match blending serially")
+	}
+
+	// translucent triangles do not write depth, so when everything is translucent nothing is
+	for i := range tilemap.Pix {
+		if i%4 == 3 {
+			tilemap.Pix[i] = 128
+		}
+	}
+	scene.meshCache.Reset()
+	drawTestScene(&scene, img, &depthBuffer)
+	numBlended := 0
+	for i := range depthBuffer {
+		if depthBuffer[i] != 1e9 {
+			t.Fatalf("Expected translucent triangles not to write depth at pixel %d", i)
+		}
+		if img.Pix[i*4] != 0 || img.Pix[i*4+1] != 0 || img.Pix[i*4+2] != 0 {
+			numBlended++
+		}
+	}
+	if numBlended == 0 {
+		t.Errorf("Expected translucent triangles to be blended into the image")
+	}
+}
+
+func TestTextureSampler(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if x < 4 {
+				img.SetRGBA(x, y, red)
+			} else {
+				img.SetRGBA(x, y, blue)
+			}
+		}
+	}
+	// a transparent texel does not darken the average
+	img.SetRGBA(0, 0, color.RGBA{})
+
+	mips := GenerateMips(img, 5)
+	if len(mips) != 3 {
+		t.Fatalf("Expected mip levels until the image is 1 pixel, got %d", len(mips))
+	}
+	for i, mip := range mips[:2] {
+		if size := mip.Bounds().Size(); size != image.Pt(4>>i, 4>>i) {
+			t.Errorf("Expected mip level %d to be half the size of the level before, got %v", i+1, size)
+		}
+		// each half is a tile whose size is divisible by 4, so stays separate in both levels
+		if c := mip.RGBAAt(mip.Bounds().Dx()-1, 0); c != blue {
+			t.Errorf("Expected mip level %d to keep the right tile blue, got %v", i+1, c)
+		}
+		if c := mip.RGBAAt(0, mip.Bounds().Dy()-1); c != red {
+			t.Errorf("Expected mip level %d to keep the left tile red, got %v", i+1, c)
+		}
+	}
+	if c := mips[0].RGBAAt(0, 0); c.R != 255 || c.A != 191 {
+		t.Errorf("Expected the transparent texel to lower the alpha but not the colour, got %v", c)
+	}
+
+	texture := &mipTexture{append([]*image.RGBA{img}, mips...), TextureSampler{}}
+	// a triangle with u, v covering 4 texels per pixel at a depth of 1
+	v := [3]Vertex2{
+		{X: 0, Y: 0, Z: 1, U: 0, V: 0, TZ: 1},
+		{X: 10, Y: 0, Z: 1, U: 40, V: 0, TZ: 1},
+		{X: 0, Y: 10, Z: 1, U: 0, V: 40, TZ: 1},
+	}
+	grad := triangleTexGradients(v)
+	if level := texture.level(&grad, 4, 4, 1); level != 0 {
+		t.Errorf("Expected level 0 without mipmaps, got %d", level)
+	}
+	texture.sampler.Mipmaps = true
+	if level := texture.level(&grad, 4, 4, 1); level != 2 {
+		t.Errorf("Expected level 2 for 4 texels per pixel, got %d", level)
+	}
+	// twice as far away, the same pixel covers twice as many texels
+	if level := texture.level(&grad, 4, 4, 0.5); level != 3 {
+		t.Errorf("Expected level 3 for 8 texels per pixel, got %d", level)
+	}
+	grad = triangleTexGradients([3]Vertex2{v[0], {X: 40, Y: 0, Z: 1, U: 40, TZ: 1}, {X: 0, Y: 40, Z: 1, V: 40, TZ: 1}})
+	if level := texture.level(&grad, 4, 4, 1); level != 0 {
+		t.Errorf("Expected level 0 for 1 texel per pixel, got %d", level)
+	}
+
+	if c := texture.sample(4.5, 2.5, 0); c != blue {
+		t.Errorf("Expected nearest filtering to sample the texel, got %v", c)
+	}
+	texture.sampler.Filter = BilinearFilter
+	if c := texture.sample(4.5, 2.5, 0); c != blue {
+		t.Errorf("Expected bilinear filtering at a texel's centre to sample the texel, got %v", c)
+	}
+	if c := texture.sample(4, 2.5, 0); c != (color.RGBA{128, 0, 128, 255}) {
+		t.Errorf("Expected bilinear filtering between two texels to blend them, got %v", c)
+	}
+
+	// filtered rendering is the same in parallel
+	width, height := 160, 120
+	scene := createTestRenderScene(width, height)
+	scene.Tilemap.Mips = GenerateMips(&scene.Tilemap.Image, maxMipLevels)
+	scene.TextureSampler = TextureSampler{Filter: BilinearFilter, Mipmaps: true}
+	scene.RenderWorkers = 1
+	expected := image.NewRGBA(image.Rect(0, 0, width, height))
+	expectedDepth := make(DepthBuffer, width*height)
+	drawTestScene(&scene, expected, &expectedDepth)
+	scene.RenderWorkers = 3
+	rendered := image.NewRGBA(image.Rect(0, 0, width, height))
+	depthBuffer := make(DepthBuffer, width*height)
+	drawTestScene(&scene, rendered, &depthBuffer)
+	if !bytes.Equal(rendered.Pix, expected.Pix) {
+		t.Errorf("Expected filtering in parallel to match filtering serially")
+	}
+}
+
+// legacyTorchCuboids is the torch's geometry from before it was loaded from a block model
+func legacyTorchCuboids(b RedstoneTorch, scene *Scene) []Cuboid {
+	s := Point3DFromScalar(16)
+	tex := "redstone_torch_off"
+	if b.IsPowered {
+		tex = "redstone_torch"
+	}
+	torch := MakeAxisAlignedCuboid(Point3D{7, 0, 7}.Divide(s), Point3D{9, 10, 9}.Divide(s),
+		color.RGBA{160, 127, 81, 255}, CreateCuboidUVs(7, 6, 2, 10, tex, scene))
+	var ry, rz float64
+	offset := Point3D{}
+	switch b.Direction {
+	case Left:
+		ry, rz, offset = 0, 45, Point3D{5, 2, 0}.Divide(s)
+	case Right:
+		ry, rz, offset = 180, 45, Point3D{-5, 2, 0}.Divide(s)
+	case Front:
+		ry, rz, offset = 90, 45, Point3D{0, 2, -5}.Divide(s)
+	case Back:
+		ry, rz, offset = 270, 45, Point3D{0, 2, 5}.Divide(s)
+	}
+	translate := Point3D{8, 3.5, 8}.Divide(s)
+	for i := range torch.vertices {
+		torch.vertices[i] = torch.vertices[i].Subtract(translate).RotateZ(DegToRad(rz)).RotateY(DegToRad(ry)).Add(translate).Add(offset)
+	}
+	return []Cuboid{torch}
+}
+
+// legacyLeverCuboids is the lever's geometry from before it was loaded from a block model
+func legacyLeverCuboids(b Lever, scene *Scene) []Cuboid {
+	s := Point3DFromScalar(16)
+	stick := MakeAxisAlignedCuboid(Point3D{7, 3, 7}.Divide(s), Point3D{9, 13, 9}.Divide(s),
+		color.RGBA{160, 127, 81, 255}, CreateCuboidUVs(7, 6, 2, 10, "lever", scene))
+	rx := -45.0
+	if b.IsOn {
+		rx = 45
+	}
+	pivot := Point3D{7, 3, 7}.Divide(s)
+	for i := range stick.vertices {
+		stick.vertices[i] = stick.vertices[i].Subtract(pivot).RotateX(DegToRad(rx)).Add(pivot)
+	}
+	base := MakeAxisAlignedCuboid(Point3D{5, 0, 4}.Divide(s), Point3D{11, 3, 12}.Divide(s),
+		color.RGBA{100, 100, 100, 255}, MakeCuboidUVsForSingleTexture("cobblestone", scene))
+	cuboids := []Cuboid{base, stick}
+	rot := map[Direction]Point3D{
+		Left: {90, 0, 90}, Right: {90, 0, -90}, Up: {0, 0, 0}, Down: {0, 0, 180}, Front: {90, 0, 0}, Back: {90, 180, 0},
+	}[b.Direction]
+	centre := Point3D{8, 8, 8}.Divide(s)
+	for j := range cuboids {
+		for i := range cuboids[j].vertices {
+			cuboids[j].vertices[i] = cuboids[j].vertices[i].Subtract(centre).
+				RotateZ(DegToRad(rot.Z)).RotateX(DegToRad(rot.X)).RotateY(DegToRad(rot.Y)).Add(centre)
+		}
+	}
+	return cuboids
+}
+
+func TestBlockModels(t *testing.T) {
+	scene := createTestRenderScene(64, 64)
+	scene.Tilemap.Metas = map[string]TextureMeta{
+		"redstone_torch":     {U: 0, V: 0, Width: 0.25, Height: 0.25},
+		"redstone_torch_off": {U: 0.25, V: 0, Width: 0.25, Height: 0.25},
+		"lever":              {U: 0.5, V: 0, Width: 0.25, Height: 0.25},
+		"cobblestone":        {U: 0.75, V: 0, Width: 0.25, Height: 0.25},
+	}
+
+	// the models reproduce the geometry the blocks were drawn with in code
+	for _, d := range []Direction{Up, Down, Left, Right, Front, Back} {
+		for _, isOn := range []bool{false, true} {
+			for _, c := range []struct {
+				block    WireRenderBlock
+				expected []Cuboid
+			}{
+				{RedstoneTorch{Direction: d, IsPowered: isOn}, legacyTorchCuboids(RedstoneTorch{Direction: d, IsPowered: isOn}, &scene)},
+				{Lever{Direction: d, IsOn: isOn}, legacyLeverCuboids(Lever{Direction: d, IsOn: isOn}, &scene)},
+			} {
+				cuboids := c.block.ToCuboids(&scene)
+				if len(cuboids) != len(c.expected) {
+					t.Fatalf("%v: Expected %d cuboids, got %d", c.block, len(c.expected), len(cuboids))
+				}
+				for i, cuboid := range cuboids {
+					expected := c.expected[i]
+					for v := range cuboid.vertices {
+						if d := cuboid.vertices[v].Subtract(expected.vertices[v]); DotProduct(d, d) > 1e-18 {
+							t.Errorf("%v: Expected vertex %d of cuboid %d at %v, got %v", c.block, v, i, expected.vertices[v], cuboid.vertices[v])
+						}
+					}
+					if cuboid.Color != expected.Color || !slices.Equal(cuboid.uvs, expected.uvs) {
+						t.Errorf("%v: Expected cuboid %d to have colour %v and uvs %v, got %v and %v",
+							c.block, i, expected.Color, expected.uvs, cuboid.Color, cuboid.uvs)
+					}
+				}
+			}
+		}
+	}
+
+	// models inherit their parent's elements, and override its textures
+	face := `{"texture": "#side", "uv": [0, 0, 16, 16]}`
+	element := `{"from": [0, 0, 0], "to": [16, 8, 16], "faces": {"up": ` + face + `, "down": ` + face +
+		`, "left": ` + face + `, "right": ` + face + `, "front": ` + face + `, "back": ` + face + `}}`
+	assets := map[string]string{
+		"blockstates/slab.json": `{"variants": {"half=bottom": {"model": "slab"}, "half=top": {"model": "slab_top", "x": 180}}}`,
+		"models/template.json":  `{"textures": {"side": "stone"}, "elements": [` + element + `]}`,
+		"models/slab.json":      `{"parent": "template"}`,
+		"models/slab_top.json":  `{"parent": "template", "textures": {"side": "#top"}}`,
+	}
+	load := func(filename string) ([]byte, error) {
+		data, exists := assets[filename]
+		if !exists {
+			return nil, fmt.Errorf("%s does not exist", filename)
+		}
+		return []byte(data), nil
+	}
+	slabs := []ModelBlock{testSlab{IsTop: false}, testSlab{IsTop: true}}
+	if _, err := loadBlockModels(slabs, load); err == nil {
+		t.Errorf("Expected an error for a texture variable which is not set")
+	}
+	assets["models/slab_top.json"] = `{"parent": "template", "textures": {"side": "#top", "top": "planks"}}`
+	models, err := loadBlockModels(slabs, load)
+	if err != nil {
+		t.Fatalf("Expected the models to load, got %v", err)
+	}
+	bottom, top := models.variants["slab"]["half=bottom"], models.variants["slab"]["half=top"]
+	if len(bottom) != 1 || len(top) != 1 {
+		t.Fatalf("Expected each variant to inherit the template's element")
+	}
+	if bottom[0].faces[0].texture != "stone" || top[0].faces[0].texture != "planks" {
+		t.Errorf("Expected the textures stone and planks, got %s and %s", bottom[0].faces[0].texture, top[0].faces[0].texture)
+	}
+	if bottom[0].color != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("Expected elements without a colour to be white, got %v", bottom[0].color)
+	}
+	// the top slab is the bottom slab turned upside down
+	for v, p := range top[0].vertices {
+		if p.Y < 0.5-1e-9 {
+			t.Errorf("Expected vertex %d of the top slab to be in the top half, got %v", v, p)
+		}
+	}
+
+	// every state of the blocks must have a variant
+	assets["blockstates/slab.json"] = `{"variants": {"half=bottom": {"model": "slab"}}}`
+	if _, err := loadBlockModels(slabs, load); err == nil {
+		t.Errorf("Expected an error for a state without a variant")
+	}
+
+	assets["models/template.json"] = `{"elements": [{"from": [0, 0, 0], "to": [16, 16, 16], "faces": {"up": ` + face + `}}]}`
+	if _, err := loadBlockModels(slabs[:1], load); err == nil {
+		t.Errorf("Expected an error for an element without all six faces")
+	}
+}
+
+// testSlab is a block with a model for each half of the block
+type testSlab struct {
+	IsTop bool
+}
+
+func (b testSlab) ModelName() string {
+	return "slab"
+}
+
+func (b testSlab) BlockState() string {
+	if b.IsTop {
+		return "half=top"
+	}
+	return "half=bottom"
+}
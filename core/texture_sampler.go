@@ -0,0 +1,179 @@
+package core
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// maxMipLevels is the number of mip levels below the full size tilemap
+const maxMipLevels = 3
+
+type TextureFilter int
+
+const (
+	// NearestFilter uses the colour of the texel the pixel is in
+	NearestFilter TextureFilter = iota
+	// BilinearFilter blends the colours of the four texels nearest the pixel
+	BilinearFilter
+	numTextureFilters
+)
+
+func (f TextureFilter) String() string {
+	switch f {
+	case NearestFilter:
+		return "nearest"
+	case BilinearFilter:
+		return "bilinear"
+	default:
+		panic("TextureFilter not implemented")
+	}
+}
+
+// TextureSampler selects how the tilemap is sampled when drawing triangles
+type TextureSampler struct {
+	Filter  TextureFilter
+	Mipmaps bool // sample smaller copies of the tilemap for faces which are far away or at a steep angle
+}
+
+func (s TextureSampler) String() string {
+	if s.Mipmaps {
+		return s.Filter.String() + "+mips"
+	}
+	return s.Filter.String()
+}
+
+func (s *TextureSampler) NextFilter() {
+	s.Filter = (s.Filter + 1) % numTextureFilters
+}
+
+// mipTexture is a texture and its mip levels, each half the size of the one before
+type mipTexture struct {
+	levels  []*image.RGBA
+	sampler TextureSampler
+}
+
+func newMipTexture(tilemap *Tilemap, sampler TextureSampler) *mipTexture {
+	levels := append([]*image.RGBA{&tilemap.Image}, tilemap.Mips...)
+	return &mipTexture{levels, sampler}
+}
+
+// singleLevelTexture is a texture with no mip levels, sampled with nearest filtering
+func singleLevelTexture(img *image.RGBA) *mipTexture {
+	return &mipTexture{levels: []*image.RGBA{img}}
+}
+
+// texGradients are the screen space gradients of a triangle's perspective divided u and v, and 1/z.
+// They are constant across the triangle, so the rate the texture coordinates change at each pixel
+// can be calculated without interpolating neighbouring pixels.
+type texGradients struct {
+	dudx, dudy float64
+	dvdx, dvdy float64
+	dwdx, dwdy float64
+}
+
+func triangleTexGradients(v [3]Vertex2) texGradients {
+	x1, y1 := float64(v[1].X-v[0].X), float64(v[1].Y-v[0].Y)
+	x2, y2 := float64(v[2].X-v[0].X), float64(v[2].Y-v[0].Y)
+	denom := x1*y2 - x2*y1
+	if denom == 0 {
+		return texGradients{}
+	}
+	gradient := func(a0, a1, a2 float64) (float64, float64) {
+		d1, d2 := a1-a0, a2-a0
+		return (d1*y2 - d2*y1) / denom, (d2*x1 - d1*x2) / denom
+	}
+	g := texGradients{}
+	g.dudx, g.dudy = gradient(v[0].U, v[1].U, v[2].U)
+	g.dvdx, g.dvdy = gradient(v[0].V, v[1].V, v[2].V)
+	g.dwdx, g.dwdy = gradient(v[0].TZ, v[1].TZ, v[2].TZ)
+	return g
+}
+
+// level returns the mip level for a pixel with the texture coordinates u, v, where the
+// interpolated 1/z is w. The level is chosen so about one texel covers the pixel.
+func (t *mipTexture) level(g *texGradients, u, v, w float64) int {
+	if !t.sampler.Mipmaps || len(t.levels) == 1 {
+		return 0
+	}
+	// derivatives of u and v, from the quotient rule as u = (u/z) / (1/z)
+	ux, uy := (g.dudx-u*g.dwdx)/w, (g.dudy-u*g.dwdy)/w
+	vx, vy := (g.dvdx-v*g.dwdx)/w, (g.dvdy-v*g.dwdy)/w
+	texelsPerPixelSq := max(ux*ux+vx*vx, uy*uy+vy*vy)
+	if texelsPerPixelSq <= 1 {
+		return 0
+	}
+	level := int(0.5*math.Log2(texelsPerPixelSq) + 0.5)
+	return min(level, len(t.levels)-1)
+}
+
+// sample returns the colour of the texture at u, v, in pixels of the full size texture, from the mip level
+func (t *mipTexture) sample(u, v float64, level int) color.RGBA {
+	img := t.levels[level]
+	if level > 0 {
+		scale := 1 / float64(int(1)<<level)
+		u *= scale
+		v *= scale
+	}
+	if t.sampler.Filter == NearestFilter {
+		return img.RGBAAt(int(u), int(v))
+	}
+
+	// blend the texels whose centres surround u, v
+	u -= 0.5
+	v -= 0.5
+	x0, y0 := int(math.Floor(u)), int(math.Floor(v))
+	fx, fy := u-float64(x0), v-float64(y0)
+	bounds := img.Bounds()
+	clampX := func(x int) int { return min(max(x, bounds.Min.X), bounds.Max.X-1) }
+	clampY := func(y int) int { return min(max(y, bounds.Min.Y), bounds.Max.Y-1) }
+	c00 := img.RGBAAt(clampX(x0), clampY(y0))
+	c10 := img.RGBAAt(clampX(x0+1), clampY(y0))
+	c01 := img.RGBAAt(clampX(x0), clampY(y0+1))
+	c11 := img.RGBAAt(clampX(x0+1), clampY(y0+1))
+	lerp := func(a, b, c, d uint8) uint8 {
+		top := float64(a) + fx*(float64(b)-float64(a))
+		bottom := float64(c) + fx*(float64(d)-float64(c))
+		return uint8(top + fy*(bottom-top) + 0.5)
+	}
+	return color.RGBA{
+		lerp(c00.R, c10.R, c01.R, c11.R),
+		lerp(c00.G, c10.G, c01.G, c11.G),
+		lerp(c00.B, c10.B, c01.B, c11.B),
+		lerp(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+// GenerateMips returns up to numLevels mip levels of img, each the average of 2x2 pixels of the
+// level before. Tiles whose padded size is divisible by 2^numLevels stay separate in every level.
+func GenerateMips(img *image.RGBA, numLevels int) []*image.RGBA {
+	var mips []*image.RGBA
+	prev := img
+	for len(mips) < numLevels {
+		w, h := prev.Bounds().Dx()/2, prev.Bounds().Dy()/2
+		if w == 0 || h == 0 {
+			break
+		}
+		mip := image.NewRGBA(image.Rect(0, 0, w, h))
+		origin := prev.Bounds().Min
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var r, g, b, a int
+				for _, o := range [...]image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+					c := prev.RGBAAt(origin.X+x*2+o.X, origin.Y+y*2+o.Y)
+					// weight by alpha, so transparent texels do not darken the colour
+					r += int(c.R) * int(c.A)
+					g += int(c.G) * int(c.A)
+					b += int(c.B) * int(c.A)
+					a += int(c.A)
+				}
+				if a > 0 {
+					mip.SetRGBA(x, y, color.RGBA{uint8(r / a), uint8(g / a), uint8(b / a), uint8(a / 4)})
+				}
+			}
+		}
+		mips = append(mips, mip)
+		prev = mip
+	}
+	return mips
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"container/heap"
+)
+
+// TickPriority orders scheduled ticks that are due on the same game tick,
+// lower values run first (matches minecraft's TickPriority)
+type TickPriority int
+
+const (
+	ExtremelyHighPriority TickPriority = iota - 3
+	VeryHighPriority
+	HighPriority
+	NormalPriority
+	LowPriority
+	VeryLowPriority
+	ExtremelyLowPriority
+)
+
+// ScheduledTickBlock is implemented by blocks that react to neighbour updates after a delay.
+// Blocks that do not implement it react instantly.
+type ScheduledTickBlock interface {
+	// TickDelay returns the number of game ticks before the block becomes next
+	TickDelay(next Block) int
+	TickPriority() TickPriority
+}
+
+type ScheduledTick struct {
+	Position Vec3
+	DueTick  int
+	Priority TickPriority
+	order    int // insertion order, breaks ties between equal due tick and priority
+}
+
+type BlockEvent struct {
+	Position Vec3
+	Block    Block
+}
+
+type scheduledTickQueue []ScheduledTick
+
+func (q scheduledTickQueue) Len() int { return len(q) }
+func (q scheduledTickQueue) Less(i, j int) bool {
+	if q[i].DueTick != q[j].DueTick {
+		return q[i].DueTick < q[j].DueTick
+	}
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority < q[j].Priority
+	}
+	return q[i].order < q[j].order
+}
+func (q scheduledTickQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *scheduledTickQueue) Push(x any)   { *q = append(*q, x.(ScheduledTick)) }
+func (q *scheduledTickQueue) Pop() any {
+	old := *q
+	n := len(old)
+	tick := old[n-1]
+	*q = old[:n-1]
+	return tick
+}
+
+// stops instant neighbour update chains from hanging the game
+const maxNeighbourUpdatesPerTick = 1 << 16
+
+// TickScheduler simulates the world like minecraft. Each game tick runs the due
+// scheduled ticks in priority order, then the queued block events. Every block change
+// notifies its neighbours, which either change instantly or schedule a tick.
+type TickScheduler struct {
+	Tick        int
+	ticks       scheduledTickQueue
+	isScheduled map[Vec3]bool
+	blockEvents []BlockEvent
+	neighbours  []Vec3
+	order       int
+	// false until the first tick after a reset, which treats every block as changed
+	hasScannedWorld bool
+	// statistics for the last tick
+	NumScheduledTicks    int
+	NumBlockEvents       int
+	NumNeighbourUpdates  int
+	NumBlockChanges      int
+	NumNeighbourOverflow int
+}
+
+// Reset clears all pending ticks, events and updates
+func (s *TickScheduler) Reset() {
+	*s = TickScheduler{}
+}
+
+// ScheduleTick schedules a tick for the block at p in delay game ticks,
+// unless the position already has a pending tick
+func (s *TickScheduler) ScheduleTick(p Vec3, delay int, priority TickPriority) {
+	if s.isScheduled == nil {
+		s.isScheduled = make(map[Vec3]bool)
+	}
+	if s.isScheduled[p] {
+		return
+	}
+	s.isScheduled[p] = true
+	heap.Push(&s.ticks, ScheduledTick{p, s.Tick + delay, priority, s.order})
+	s.order++
+}
+
+// AddBlockEvent queues a block to be placed after this tick's scheduled ticks
+func (s *TickScheduler) AddBlockEvent(p Vec3, block Block) {
+	s.blockEvents = append(s.blockEvents, BlockEvent{p, block})
+}
+
+func (s *TickScheduler) notifyNeighbours(p Vec3) {
+	for _, d := range [...]Direction{Left, Right, Down, Up, Back, Front} {
+		s.neighbours = append(s.neighbours, p.Move(d))
+	}
+}
+
+func (s *TickScheduler) setBlock(p Vec3, block Block, w *World) {
+	if w.SetBlock(p, block) {
+		s.NumBlockChanges++
+		s.notifyNeighbours(p)
+	}
+}
+
+// nextBlock returns the block that p would become given its current neighbours
+func nextBlock(p Vec3, w *World) (Block, bool) {
+	b := w.GetBlock(p)
+	if _, canSubUpdate := b.(SubUpdateableBlock); canSubUpdate {
+		return w.SubUpdateBlock(p)
+	}
+	return w.UpdateBlock(p)
+}
+
+func (s *TickScheduler) handleNeighbourUpdate(p Vec3, w *World) {
+	next, hasUpdated := nextBlock(p, w)
+	if !hasUpdated {
+		return
+	}
+	delay := 0
+	priority := NormalPriority
+	if sb, isScheduled := w.GetBlock(p).(ScheduledTickBlock); isScheduled {
+		delay = sb.TickDelay(next)
+		priority = sb.TickPriority()
+	}
+	if delay <= 0 {
+		s.setBlock(p, next, w)
+	} else {
+		s.ScheduleTick(p, delay, priority)
+	}
+}
+
+func (s *TickScheduler) processNeighbourUpdates(w *World) {
+	// handling an update can queue more updates, so the length is re-read each iteration
+	for i := 0; i < len(s.neighbours); i++ {
+		if s.NumNeighbourUpdates >= maxNeighbourUpdatesPerTick {
+			s.NumNeighbourOverflow += len(s.neighbours) - i
+			break
+		}
+		s.NumNeighbourUpdates++
+		s.handleNeighbourUpdate(s.neighbours[i], w)
+	}
+	s.neighbours = s.neighbours[:0]
+}
+
+// detectExternalChanges notifies the neighbours of, and updates, any block changed outside
+// the scheduler since the last tick (user input, world creation)
+func (s *TickScheduler) detectExternalChanges(w *World) {
+	if !s.hasScannedWorld {
+		for i, block := range w.Blocks {
+			if block != nil {
+				w.changed.add(i)
+			}
+		}
+		s.hasScannedWorld = true
+	}
+	for _, i := range w.changed.indices[:w.changed.count] {
+		p := w.GetPosition(int(i))
+		s.neighbours = append(s.neighbours, p)
+		s.notifyNeighbours(p)
+	}
+	w.changed.clear()
+	s.processNeighbourUpdates(w)
+}
+
+// UpdateWorld advances the world by one game tick
+func (s *TickScheduler) UpdateWorld(w *World) {
+	s.NumScheduledTicks = 0
+	s.NumBlockEvents = 0
+	s.NumNeighbourUpdates = 0
+	s.NumBlockChanges = 0
+	s.NumNeighbourOverflow = 0
+
+	s.detectExternalChanges(w)
+
+	// scheduled ticks
+	for len(s.ticks) > 0 && s.ticks[0].DueTick <= s.Tick {
+		tick := heap.Pop(&s.ticks).(ScheduledTick)
+		delete(s.isScheduled, tick.Position)
+		s.NumScheduledTicks++
+		// the block may have been changed since the tick was scheduled, so re-evaluate it
+		next, hasUpdated := nextBlock(tick.Position, w)
+		if hasUpdated {
+			s.setBlock(tick.Position, next, w)
+		}
+		s.processNeighbourUpdates(w)
+	}
+
+	// block events
+	blockEvents := s.blockEvents
+	s.blockEvents = nil
+	for _, event := range blockEvents {
+		s.NumBlockEvents++
+		s.setBlock(event.Position, event.Block, w)
+		s.processNeighbourUpdates(w)
+	}
+
+	// the scheduler's own changes have already notified their neighbours
+	w.changed.clear()
+	s.Tick++
+}
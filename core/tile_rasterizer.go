@@ -0,0 +1,114 @@
+package core
+
+import (
+	"image"
+	"sync"
+	"sync/atomic"
+)
+
+const rasterTileSize = 64 // pixels
+
+type rasterTriangle struct {
+	v       [3]Vertex2
+	texture *mipTexture
+	shade   float64
+	blend   bool
+}
+
+type rasterTile struct {
+	clip      image.Rectangle
+	triangles []int32 // indices of the triangles that overlap the tile, in the order they were added
+}
+
+// TileRasterizer bins triangles into screen tiles, which are rasterized in parallel.
+// Each tile is drawn by one worker, which owns the tile's pixels and depth, in the
+// order the triangles were added, so the image is identical to drawing on one goroutine.
+type TileRasterizer struct {
+	triangles []rasterTriangle
+	tiles     []rasterTile
+	clip      image.Rectangle
+}
+
+// Reset removes all triangles, and splits an image of size into tiles
+func (r *TileRasterizer) Reset(size image.Point) {
+	r.triangles = r.triangles[:0]
+	clip := image.Rect(0, 0, size.X-1, size.Y-1)
+	if clip == r.clip && r.tiles != nil {
+		for i := range r.tiles {
+			r.tiles[i].triangles = r.tiles[i].triangles[:0]
+		}
+		return
+	}
+	r.clip = clip
+	r.tiles = r.tiles[:0]
+	for y := clip.Min.Y; y < clip.Max.Y; y += rasterTileSize {
+		for x := clip.Min.X; x < clip.Max.X; x += rasterTileSize {
+			tile := image.Rect(x, y, x+rasterTileSize, y+rasterTileSize).Intersect(clip)
+			r.tiles = append(r.tiles, rasterTile{clip: tile})
+		}
+	}
+}
+
+func (r *TileRasterizer) numTilesX() int {
+	return (r.clip.Dx() + rasterTileSize - 1) / rasterTileSize
+}
+
+// AddTriangle projects the triangle, and adds each triangle of the clipped polygon to the tiles its bounds overlap
+// The triangle is shaded by the light model, lit by the block light level and darkened by the
+// ambient occlusion of each vertex. If blend, the texture is alpha blended.
+func (r *TileRasterizer) AddTriangle(v1, v2, v3 Vertex, ao [3]float64, frustum Frustum, texture *mipTexture, blend bool, lightModel *LightModel, blockLight int) {
+	imageSize := Point2D{float64(r.clip.Max.X + 1), float64(r.clip.Max.Y + 1)}
+	var points [maxClipVertices]ImgPoint
+	polygon, normal := projectTriangle(v1, v2, v3, lightModel.occlusion(ao), frustum, imageSize, &points)
+	if len(polygon) == 0 {
+		return
+	}
+	intensity := lightModel.Shade(normal, blockLight)
+	for i := 2; i < len(polygon); i++ {
+		r.addProjectedTriangle(polygon[0], polygon[i-1], polygon[i], texture, intensity, blend)
+	}
+}
+
+func (r *TileRasterizer) addProjectedTriangle(p1, p2, p3 ImgPoint, texture *mipTexture, intensity float64, blend bool) {
+	bounds := image.Rect(
+		min(p1.X, p2.X, p3.X), min(p1.Y, p2.Y, p3.Y),
+		max(p1.X, p2.X, p3.X)+1, max(p1.Y, p2.Y, p3.Y)+1,
+	).Intersect(r.clip)
+	if bounds.Empty() {
+		return
+	}
+
+	index := int32(len(r.triangles))
+	r.triangles = append(r.triangles, rasterTriangle{toVertex2s(p1, p2, p3), texture, intensity, blend})
+	numTilesX := r.numTilesX()
+	for ty := bounds.Min.Y / rasterTileSize; ty <= (bounds.Max.Y-1)/rasterTileSize; ty++ {
+		for tx := bounds.Min.X / rasterTileSize; tx <= (bounds.Max.X-1)/rasterTileSize; tx++ {
+			tile := &r.tiles[ty*numTilesX+tx]
+			tile.triangles = append(tile.triangles, index)
+		}
+	}
+}
+
+// Rasterize draws the triangles to img and depthBuffer, which must be the size given to Reset
+func (r *TileRasterizer) Rasterize(img *image.RGBA, depthBuffer *DepthBuffer, workers int) {
+	var next atomic.Int32
+	var wg sync.WaitGroup
+	for w := 0; w < max(1, min(workers, len(r.tiles))); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(r.tiles) {
+					return
+				}
+				tile := &r.tiles[i]
+				for _, t := range tile.triangles {
+					tri := &r.triangles[t]
+					renderTriangle(img, tri.texture, tri.v, depthBuffer, tri.shade, tri.blend, tile.clip)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
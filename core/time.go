@@ -4,7 +4,10 @@ import (
 	_ "unsafe" // This is required for go:linkname to work
 )
 
-//go:linkname nanotime runtime.nanotime1
+// runtime.nanotime rather than runtime.nanotime1, since Go 1.23 the linker rejects
+// linknames to runtime symbols that are not explicitly exported for them
+//
+//go:linkname nanotime runtime.nanotime
 func nanotime() int64
 
 func NowInSeconds() float64 {
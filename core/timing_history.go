@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// the number of calls kept per event, about 4 seconds at 60 FPS
+const timingHistoryLength = 256
+
+// TimingHistory is a rolling history of how long an event's calls took,
+// and how late each call started (jitter)
+type TimingHistory struct {
+	durations [timingHistoryLength]int64 // nanoseconds
+	jitters   [timingHistoryLength]int64 // nanoseconds
+	next      int
+	count     int
+}
+
+func (h *TimingHistory) Add(duration int64, jitter int64) {
+	h.durations[h.next] = duration
+	h.jitters[h.next] = jitter
+	h.next = (h.next + 1) % timingHistoryLength
+	h.count = min(h.count+1, timingHistoryLength)
+}
+
+func (h *TimingHistory) Len() int {
+	return h.count
+}
+
+// ordered returns the values in the order they were added
+func (h *TimingHistory) ordered(values *[timingHistoryLength]int64) []int64 {
+	start := (h.next - h.count + timingHistoryLength) % timingHistoryLength
+	ordered := make([]int64, h.count)
+	for i := range ordered {
+		ordered[i] = values[(start+i)%timingHistoryLength]
+	}
+	return ordered
+}
+
+// Durations returns the durations of the calls, oldest first
+func (h *TimingHistory) Durations() []int64 {
+	return h.ordered(&h.durations)
+}
+
+// Jitters returns how late each call started, oldest first
+func (h *TimingHistory) Jitters() []int64 {
+	return h.ordered(&h.jitters)
+}
+
+type TimingSummary struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+func (s TimingSummary) String() string {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return fmt.Sprintf("%.1f/%.1f/%.1f/%.1fms", ms(s.P50), ms(s.P95), ms(s.P99), ms(s.Max))
+}
+
+// percentile returns the nearest rank percentile p, from 0 to 100, of sorted values
+func percentile(sorted []int64, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return time.Duration(sorted[max(rank-1, 0)])
+}
+
+func summarise(values []int64) TimingSummary {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return TimingSummary{
+		P50: percentile(values, 50),
+		P95: percentile(values, 95),
+		P99: percentile(values, 99),
+		Max: percentile(values, 100),
+	}
+}
+
+func (h *TimingHistory) DurationSummary() TimingSummary {
+	return summarise(h.Durations())
+}
+
+func (h *TimingHistory) JitterSummary() TimingSummary {
+	return summarise(h.Jitters())
+}
+
+// TimingHistoriesToCSV writes one row per recorded call of every event
+func TimingHistoriesToCSV(histories map[string]*TimingHistory) []byte {
+	names := make([]string, 0, len(histories))
+	for name := range histories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b bytes.Buffer
+	b.WriteString("event,call,duration_ns,jitter_ns\n")
+	for _, name := range names {
+		h := histories[name]
+		jitters := h.Jitters()
+		for i, duration := range h.Durations() {
+			fmt.Fprintf(&b, "%s,%d,%d,%d\n", name, i, duration, jitters[i])
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+type translucentTriangle struct {
+	t        *meshTriangle
+	distance float64 // squared distance from the camera to the triangle's centre
+}
+
+// translucentPass collects the visible translucent triangles of a frame. They are alpha
+// blended over what is behind them, so are drawn after the opaque triangles, furthest first.
+type translucentPass struct {
+	triangles []translucentTriangle
+}
+
+func (p *translucentPass) Reset() {
+	p.triangles = p.triangles[:0]
+}
+
+func (p *translucentPass) Add(t *meshTriangle, camera Point3D) {
+	centre := t.v[0].Position.Add(t.v[1].Position).Add(t.v[2].Position).Divide(Point3D{3, 3, 3})
+	d := centre.Subtract(camera)
+	p.triangles = append(p.triangles, translucentTriangle{t, DotProduct(d, d)})
+}
+
+// Sorted returns the triangles furthest from the camera first, triangles at the same
+// distance stay in the order they were added
+func (p *translucentPass) Sorted() []translucentTriangle {
+	sort.SliceStable(p.triangles, func(i, j int) bool {
+		return p.triangles[i].distance > p.triangles[j].distance
+	})
+	return p.triangles
+}
+
+// uvBounds returns the pixels of the texture covered by the triangle's uvs, at least the pixel
+// sampled at the first vertex
+func uvBounds(v1, v2, v3 Vertex) image.Rectangle {
+	r := image.Rect(
+		int(math.Floor(min(v1.U, v2.U, v3.U))), int(math.Floor(min(v1.V, v2.V, v3.V))),
+		int(math.Ceil(max(v1.U, v2.U, v3.U))), int(math.Ceil(max(v1.V, v2.V, v3.V))),
+	)
+	return r.Union(image.Rect(int(v1.U), int(v1.V), int(v1.U)+1, int(v1.V)+1))
+}
+
+// hasTranslucentPixels returns whether any pixel of texture in r is not fully opaque
+func hasTranslucentPixels(texture *image.RGBA, r image.Rectangle) bool {
+	r = r.Intersect(texture.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if texture.RGBAAt(x, y).A < 255 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -225,3 +225,27 @@ func WriteGameSame(gameSave GameSave) {
 		panic(fmt.Sprintf("Error writing JSON to file: %s", err))
 	}
 }
+
+const inputLogFileName = "save/inputs.json"
+
+func SaveInputLog(inputLog *InputLog) error {
+	data, err := EncodeInputLog(inputLog)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(inputLogFileName, data, 0644)
+}
+
+func LoadInputLog() (InputLog, error) {
+	data, err := os.ReadFile(inputLogFileName)
+	if err != nil {
+		return InputLog{}, fmt.Errorf("error opening file: %w", err)
+	}
+	return DecodeInputLog(data)
+}
+
+const frameTimesFileName = "save/frame_times.csv"
+
+func SaveFrameTimes(data []byte) error {
+	return os.WriteFile(frameTimesFileName, data, 0644)
+}
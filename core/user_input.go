@@ -19,7 +19,8 @@ func createSimpleWorld(world *World) {
 	// }
 }
 
-func createWorld(world *World) {
+// CreateWorld builds the demo world shown when the game starts
+func CreateWorld(world *World) {
 	for x := 0; x < 16; x++ {
 		for z := 0; z < 16; z++ {
 			p := Vec3{X: x, Y: 0, Z: z}
@@ -121,14 +122,18 @@ func toggleLever(p Vec3, world *World) bool {
 	return false
 }
 
-func ProcessUserInputs(iteration int, world *World) bool {
+func ProcessUserInputs(scene *Scene) bool {
 	// currently just handles programatic changes to the world to simulate user interaction
+	iteration, world := scene.Iteration, &scene.World
 	var hasAnyBlockUpdated bool = false
 	if iteration == 0 {
-		createWorld(world)
+		CreateWorld(world)
 		// createSimpleWorld(world)
 		hasAnyBlockUpdated = true
 	}
+	if ReplayInputs(scene) {
+		hasAnyBlockUpdated = true
+	}
 	// if iteration%32 == 4 || iteration%32 == 20 {
 	// 	if toggleLever(Vec3{X: 0, Y: 2, Z: 2}, world) {
 	// 		hasAnyBlockUpdated = true
@@ -148,6 +153,16 @@ func ProcessUserInputs(iteration int, world *World) bool {
 	return hasAnyBlockUpdated
 }
 
+// resetScene restarts the simulation from the demo world
+func resetScene(scene *Scene) {
+	scene.World = World{Rules: scene.World.Rules}
+	scene.Iteration = 0
+	scene.Scheduler.Reset()
+	scene.PeriodDetector.Reset()
+	scene.History.Reset()
+	CreateWorld(&scene.World)
+}
+
 func HandleKeyPress(scene *Scene, key string, moveDelta float64, rotDelta float64) {
 	camera := &scene.Camera
 	// delta := 0.5
@@ -163,16 +178,113 @@ func HandleKeyPress(scene *Scene, key string, moveDelta float64, rotDelta float6
 		} else if scene.GameState == Playing {
 			scene.GameState = Paused
 		}
-	case "o":
-		if scene.GameState == Paused {
+	case "o", "i":
+		// step 1 or 10 ticks
+		if scene.GameState == Paused || (key == "i" && scene.GameState == Playing) {
 			scene.GameState = Pausing
+			scene.StepsToRun = map[string]int{"o": 1, "i": 10}[key]
 		} else if scene.GameState == Playing {
 			scene.GameState = Paused
 		}
+	case "-", "=", "+":
+		ChangeSpeed(scene, key != "-")
+		fmt.Println("Speed:", scene.SpeedString())
+	case "[", "]":
+		scene.StepsPerSecond = changeRate(scene.StepsPerSecond, key == "]")
+		fmt.Println("Steps per second:", scene.StepsPerSecond)
+	case "{", "}":
+		scene.FramesPerSecond = changeRate(scene.FramesPerSecond, key == "}")
+		fmt.Println("Frames per second:", scene.FramesPerSecond)
+	case "f":
+		scene.MaxSpeed = !scene.MaxSpeed
+		fmt.Println("Speed:", scene.SpeedString())
+	case "n":
+		scene.LightModel.NextMode()
+		fmt.Println("Light:", scene.LightModel.Mode)
+	case "N":
+		scene.LightModel.RotateSun(DegToRad(30))
+		fmt.Printf("Sun: %.0f degrees\n", scene.LightModel.sunAzimuth())
+	case "O":
+		scene.LightModel.AmbientOcclusion = !scene.LightModel.AmbientOcclusion
+		fmt.Println("Ambient occlusion:", scene.LightModel.AmbientOcclusion)
+	case "t":
+		scene.TextureSampler.NextFilter()
+		fmt.Println("Texture sampler:", scene.TextureSampler)
+	case "T":
+		scene.TextureSampler.Mipmaps = !scene.TextureSampler.Mipmaps
+		fmt.Println("Texture sampler:", scene.TextureSampler)
+	case "g":
+		scene.ShowFrameTimeGraph = !scene.ShowFrameTimeGraph
+	case "h":
+		if err := SaveFrameTimes(TimingHistoriesToCSV(scene.EventTimings)); err != nil {
+			fmt.Println("Failed to save frame times:", err)
+		} else {
+			fmt.Println("Saved frame times")
+		}
 	case "r":
-		scene.World = World{}
-		scene.Iteration = 0
-		createWorld(&scene.World)
+		resetScene(scene)
+		scene.StartRecording()
+	case "k":
+		if err := SaveInputLog(&scene.InputLog); err != nil {
+			fmt.Println("Failed to save inputs:", err)
+		} else {
+			fmt.Println("Saved", len(scene.InputLog.Actions), "inputs")
+		}
+	case "j":
+		inputLog, err := LoadInputLog()
+		if err != nil {
+			fmt.Println("Failed to load inputs:", err)
+			break
+		}
+		resetScene(scene)
+		scene.StartReplay(inputLog)
+		fmt.Println("Replaying", len(inputLog.Actions), "inputs")
+	case ",", "<":
+		n := map[string]int{",": 1, "<": 10}[key]
+		scene.GameState = Paused
+		fmt.Println("Rewound", scene.History.Rewind(scene, n), "ticks")
+	case ".", ">":
+		n := map[string]int{".": 1, ">": 10}[key]
+		fmt.Println("Forwarded", scene.History.Forward(scene, n), "ticks")
+	case "v":
+		// break when the selected block changes
+		_, selectedPos := GetRayCastPositions(scene)
+		if selectedPos != nil {
+			b := BlockBreakpoint(*selectedPos, BreakOnChange)
+			if scene.ToggleBreakpoint(b) {
+				fmt.Println("Added breakpoint:", b)
+			} else {
+				fmt.Println("Removed breakpoint:", b)
+			}
+		}
+	case "V":
+		scene.Breakpoints = nil
+		fmt.Println("Removed all breakpoints")
+	case "b":
+		scene.BranchHistory()
+		fmt.Println("Branched at iteration", scene.Iteration)
+	case "m":
+		scene.Input(InputAction{Type: ToggleSimulationModeAction})
+		fmt.Println("Simulation mode:", scene.SimulationMode)
+	case "u":
+		scene.Input(InputAction{Type: ToggleQuasiConnectivityAction})
+		fmt.Println("Quasi-connectivity:", scene.World.Rules.QuasiConnectivity)
+	case "y":
+		scene.PauseOnOscillation = !scene.PauseOnOscillation
+		fmt.Println("Pause on oscillation:", scene.PauseOnOscillation)
+	case "l":
+		// detect the period of the blocks around the selected block, or the whole world
+		_, selectedPos := GetRayCastPositions(scene)
+		if scene.PeriodDetector.Region != nil || selectedPos == nil {
+			scene.PeriodDetector.SetRegion(nil)
+		} else {
+			k := Vec3{2, 2, 2}
+			scene.PeriodDetector.SetRegion(&WorldRegion{
+				Min: selectedPos.Subtract(k),
+				Max: selectedPos.Add(k).Add(Vec3{1, 1, 1}),
+			})
+		}
+		fmt.Println("Period detection region:", scene.PeriodDetector.Region)
 	case "w":
 		camera.Position = camera.Position.Add(Point3D{0, 0, moveDelta}.RotateY(-camera.Rotation.Y))
 	case "a":
@@ -83,7 +83,7 @@ func sizeCanvas(state *State) (width int, height int) {
 func createGameUpdate(state *State) func(event *GameEvent, gameLoopManager *GameLoopManager) {
 	update := func(event *GameEvent, gameLoopManager *GameLoopManager) {
 		// keyboardManager.Update()
-		Update(&state.scene)
+		RunUpdateEvent(&state.scene, event.Period)
 		if state.scene.GameState == Quit {
 			close(state.quit)
 		}
@@ -118,12 +118,13 @@ func createGameRender(state *State) func(event *GameEvent, gameLoopManager *Game
 
 func createGameUpdateStatistics(state *State) func(event *GameEvent, gameLoopManager *GameLoopManager) {
 	updateStatistics := func(event *GameEvent, gameLoopManager *GameLoopManager) {
-		renderEvent := &gameLoopManager.Events[1]
+		renderEvent := gameLoopManager.GetEvent("Render")
 		state.scene.RecordedFramesPerSecond = renderEvent.CallCount
 		renderEvent.CallCount = 0
 
-		updateEvent := &gameLoopManager.Events[0]
-		state.scene.RecordedStepsPerSecond = updateEvent.CallCount
+		updateEvent := gameLoopManager.GetEvent("Update")
+		state.scene.RecordedStepsPerSecond = state.scene.numStepsRun
+		state.scene.numStepsRun = 0
 		updateEvent.CallCount = 0
 	}
 	return updateStatistics
@@ -163,19 +164,25 @@ func runProgram() {
 	defer cleanupMouseListener()
 	SetupMouseClickEvents(&state.scene)
 	go KeyboardEvents(&state.scene)
-	go RunGameSave(&state.scene) // consider moving inside update
 
 	createOnResizeListener(&state)
 
 	g := GameLoopManager{}
 
-	events := [3]GameEvent{
-		CreateGameEvent("Update", (1000/10)*time.Millisecond, createGameUpdate(&state)),
-		CreateGameEvent("Render", (1_000_000/60)*time.Microsecond, createGameRender(&state)),
+	state.scene.StepsPerSecond = 10
+	state.scene.FramesPerSecond = 60
+	stepsPerSecond := func() int { return state.scene.StepsPerSecond }
+	framesPerSecond := func() int { return state.scene.FramesPerSecond }
+	events := []GameEvent{
+		CreateRateGameEvent("Update", stepsPerSecond, createGameUpdate(&state)),
+		CreateRateGameEvent("Render", framesPerSecond, createGameRender(&state)),
 		CreateGameEvent("Statistics", (1000/1)*time.Millisecond, createGameUpdateStatistics(&state)),
+		CreateGameSaveEvent(&state.scene),
 	}
 
 	sleepUndershoot := 5 * time.Millisecond
+	// the timings of events added later are shown and exported too
+	g.OnEventsChanged = func(g *GameLoopManager) { state.scene.EventTimings = g.TimingHistories() }
 	g.Initialise(events, sleepUndershoot, state.quit)
 
 	g.Run()
@@ -264,6 +271,36 @@ func WriteGameSame(gameSave GameSave) {
 	WriteToLocalStorage("game", gameSave)
 }
 
+func SaveInputLog(inputLog *InputLog) error {
+	data, err := EncodeInputLog(inputLog)
+	if err != nil {
+		return err
+	}
+	WriteBytesToLocalStorage("inputs", data)
+	return nil
+}
+
+func LoadInputLog() (InputLog, error) {
+	data, err := ReadBytesFromLocalStorage("inputs")
+	if err != nil {
+		return InputLog{}, err
+	}
+	return DecodeInputLog(data)
+}
+
+// SaveFrameTimes downloads the frame times as a CSV file
+func SaveFrameTimes(data []byte) error {
+	document := js.Global().Get("document")
+	blob := js.Global().Get("Blob").New([]any{string(data)}, map[string]any{"type": "text/csv"})
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	link := document.Call("createElement", "a")
+	link.Set("href", url)
+	link.Set("download", "frame_times.csv")
+	link.Call("click")
+	js.Global().Get("URL").Call("revokeObjectURL", url)
+	return nil
+}
+
 // MOUSE CONTROL
 
 func JSGetNow() float64 {
@@ -437,7 +474,7 @@ func ReadFromLocalStorage(key string, data any) error {
 // MOUSE CLICK
 
 func SetupMouseClickEvents(scene *Scene) {
-	var selectedBlock Block = &WoolBlock{Cyan, None}
+	var selectedBlock Block = WoolBlock{Cyan, None}
 	// var x DirectionalBlock = &RedstoneTorch{Left, false}
 	handleMouseClick := func(this js.Value, args []js.Value) any {
 		if !js.Global().Get("document").Get("pointerLockElement").Truthy() {
@@ -455,7 +492,7 @@ func SetupMouseClickEvents(scene *Scene) {
 				block := scene.World.GetBlock(*selectedPos)
 				_, isLever := block.(Lever)
 				if isLever {
-					toggleLever(*selectedPos, &scene.World)
+					scene.Input(InputAction{Type: ToggleLeverAction, Position: *selectedPos})
 					return nil
 				}
 			}
@@ -473,7 +510,7 @@ func SetupMouseClickEvents(scene *Scene) {
 					block = selectedBlock
 					// fmt.Println("!isDirectionalBlock", block)
 				}
-				scene.World.SetBlock(*previousPos, block)
+				scene.Input(InputAction{Type: PlaceBlockAction, Position: *previousPos, Block: block})
 			}
 			return nil
 		case 2:
@@ -481,7 +518,7 @@ func SetupMouseClickEvents(scene *Scene) {
 			// destroy block
 			_, selectedPos := GetRayCastPositions(scene)
 			if selectedPos != nil {
-				scene.World.SetBlock(*selectedPos, Air{})
+				scene.Input(InputAction{Type: DestroyBlockAction, Position: *selectedPos})
 			}
 			return nil
 		default:
@@ -1,19 +1,99 @@
 package core
 
+import "sync"
+
 const (
 	WorldWidth  = 16
 	WorldHeight = 16
 	WorldDepth  = 16
+	WorldSize   = WorldWidth * WorldHeight * WorldDepth
 )
 
+// WorldRules are edition specific behaviours that change how blocks are powered
+type WorldRules struct {
+	// QuasiConnectivity makes pistons and dispensers also powered by anything
+	// that would power the block above them (Java Edition). Bedrock disables it.
+	// Only IsQuasiConnectedPowered reads it, as there are no pistons or dispensers yet.
+	QuasiConnectivity bool
+}
+
+// worldIndexSet is a set of block indices which keeps insertion order.
+// Uses arrays rather than slices so copies of a world do not share state.
+type worldIndexSet struct {
+	contains [WorldSize]bool
+	indices  [WorldSize]uint16
+	count    int
+}
+
+func (s *worldIndexSet) add(i int) {
+	if !s.contains[i] {
+		s.contains[i] = true
+		s.indices[s.count] = uint16(i)
+		s.count++
+	}
+}
+
+func (s *worldIndexSet) clear() {
+	for _, i := range s.indices[:s.count] {
+		s.contains[i] = false
+	}
+	s.count = 0
+}
+
+// activeOffsets are the positions whose update may read a block. Blocks read their
+// neighbours, and for quasi-connectivity the neighbours of the block above.
+var activeOffsets = func() []Vec3 {
+	var offsets []Vec3
+	for x := -2; x <= 2; x++ {
+		for y := -2; y <= 2; y++ {
+			for z := -2; z <= 2; z++ {
+				if abs(x)+abs(y)+abs(z) <= 2 {
+					offsets = append(offsets, Vec3{x, y, z})
+				}
+			}
+		}
+	}
+	return offsets
+}()
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 type World struct {
-	Blocks [WorldWidth * WorldHeight * WorldDepth]Block
+	Blocks [WorldSize]Block
+	Rules  WorldRules
+	// positions whose neighbourhood has changed since their last update / sub update
+	activeUpdates    worldIndexSet
+	activeSubUpdates worldIndexSet
+	// positions set since the tick scheduler last read them
+	changed worldIndexSet
+	// while isHashTracked, SetBlock keeps hash equal to the hash of every block
+	hash          uint64
+	isHashTracked bool
+}
+
+// SetQuasiConnectivity changes the rule. No block's power depends on it yet, so no
+// positions are queued for updates; mark the affected blocks active once one does.
+func (w *World) SetQuasiConnectivity(enabled bool) {
+	w.Rules.QuasiConnectivity = enabled
 }
 
 func (w *World) GetIndex(p Vec3) int {
 	return p.Z*WorldWidth*WorldHeight + p.Y*WorldWidth + p.X
 }
 
+func (w *World) GetPosition(i int) Vec3 {
+	return Vec3{
+		X: i % WorldWidth,
+		Y: (i / WorldWidth) % WorldHeight,
+		Z: i / (WorldWidth * WorldHeight),
+	}
+}
+
 func (w *World) GetBlock(p Vec3) Block {
 	if p.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
 		return Air{}
@@ -29,10 +109,38 @@ func (w *World) SetBlock(p Vec3, block Block) bool {
 	if p.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
 		return false
 	}
-	w.Blocks[w.GetIndex(p)] = block
+	i := w.GetIndex(p)
+	if w.isHashTracked {
+		w.hash += blockHash(i, block) - blockHash(i, w.Blocks[i])
+	}
+	w.Blocks[i] = block
+	w.changed.add(i)
+	w.markActive(p)
 	return true
 }
 
+// markActive queues every position that may read p to be updated next pass
+func (w *World) markActive(p Vec3) {
+	for _, offset := range activeOffsets {
+		q := p.Add(offset)
+		if q.InRange(*Vec3FromScalar(0), *Vec3FromScalar(16)) {
+			continue
+		}
+		i := w.GetIndex(q)
+		w.activeUpdates.add(i)
+		w.activeSubUpdates.add(i)
+	}
+}
+
+// MarkAllActive queues every position to be updated next pass,
+// needed when something other than a block changes, such as the world rules
+func (w *World) MarkAllActive() {
+	for i := range w.Blocks {
+		w.activeUpdates.add(i)
+		w.activeSubUpdates.add(i)
+	}
+}
+
 func (w *World) UpdateBlock(p Vec3) (Block, bool) {
 	b := w.GetBlock(p)
 	ub, canUpdate := b.(UpdateableBlock)
@@ -51,40 +159,93 @@ func (w *World) SubUpdateBlock(p Vec3) (Block, bool) {
 	return b, false
 }
 
-func (w *World) UpdateWorld() int {
-	nextWorld := World{}
-	numUpdates := 0
-	for x := 0; x < WorldWidth; x++ {
-		for y := 0; y < WorldHeight; y++ {
-			for z := 0; z < WorldDepth; z++ {
-				p := Vec3{X: x, Y: y, Z: z}
-				block, hasUpdated := w.UpdateBlock(p)
-				if hasUpdated {
-					numUpdates += 1
-				}
-				nextWorld.SetBlock(p, block)
+type blockChange struct {
+	position Vec3
+	block    Block
+}
+
+// below this many blocks per worker, the cost of starting goroutines outweighs the update
+const minBlocksPerWorker = 64
+
+// updatePass updates the blocks at indices simultaneously, every block is evaluated against
+// the world before any are changed. The indices are split into contiguous chunks between
+// workers, and the changes applied in chunk order, so the result does not depend on the
+// number of workers. Returns the number of blocks that changed.
+func (w *World) updatePass(indices []uint16, active *worldIndexSet, updateBlock func(p Vec3) (Block, bool), workers int) int {
+	workers = max(1, min(workers, len(indices)/minBlocksPerWorker))
+	chunkSize := (len(indices) + workers - 1) / workers
+	changes := make([][]blockChange, workers)
+	evaluate := func(chunk int) {
+		start := min(chunk*chunkSize, len(indices))
+		end := min(start+chunkSize, len(indices))
+		for _, i := range indices[start:end] {
+			p := w.GetPosition(int(i))
+			block, hasUpdated := updateBlock(p)
+			if hasUpdated {
+				changes[chunk] = append(changes[chunk], blockChange{p, block})
 			}
 		}
 	}
-	*w = nextWorld
-	return numUpdates
-}
 
-func (w *World) SubUpdateWorld() int {
-	nextWorld := World{}
+	if workers == 1 {
+		evaluate(0)
+	} else {
+		var wg sync.WaitGroup
+		for chunk := 0; chunk < workers; chunk++ {
+			wg.Add(1)
+			go func(chunk int) {
+				defer wg.Done()
+				evaluate(chunk)
+			}(chunk)
+		}
+		wg.Wait()
+	}
+
+	active.clear()
 	numUpdates := 0
-	for x := 0; x < WorldWidth; x++ {
-		for y := 0; y < WorldHeight; y++ {
-			for z := 0; z < WorldDepth; z++ {
-				p := Vec3{X: x, Y: y, Z: z}
-				block, hasUpdated := w.SubUpdateBlock(p)
-				if hasUpdated {
-					numUpdates += 1
-				}
-				nextWorld.SetBlock(p, block)
-			}
+	for _, chunkChanges := range changes {
+		for _, change := range chunkChanges {
+			w.SetBlock(change.position, change.block)
 		}
+		numUpdates += len(chunkChanges)
 	}
-	*w = nextWorld
 	return numUpdates
 }
+
+var allWorldIndices = func() []uint16 {
+	indices := make([]uint16, WorldSize)
+	for i := range indices {
+		indices[i] = uint16(i)
+	}
+	return indices
+}()
+
+// UpdateWorld updates only the blocks whose neighbourhood changed since the last update
+func (w *World) UpdateWorld() int {
+	return w.ParallelUpdateWorld(1)
+}
+
+// SubUpdateWorld sub updates only the blocks whose neighbourhood changed since the last sub update
+func (w *World) SubUpdateWorld() int {
+	return w.ParallelSubUpdateWorld(1)
+}
+
+// ParallelUpdateWorld is UpdateWorld split across workers goroutines
+func (w *World) ParallelUpdateWorld(workers int) int {
+	return w.updatePass(w.activeUpdates.indices[:w.activeUpdates.count], &w.activeUpdates, w.UpdateBlock, workers)
+}
+
+// ParallelSubUpdateWorld is SubUpdateWorld split across workers goroutines
+func (w *World) ParallelSubUpdateWorld(workers int) int {
+	return w.updatePass(w.activeSubUpdates.indices[:w.activeSubUpdates.count], &w.activeSubUpdates, w.SubUpdateBlock, workers)
+}
+
+// FullUpdateWorld updates every block in the world, giving the same result as UpdateWorld
+func (w *World) FullUpdateWorld() int {
+	return w.updatePass(allWorldIndices, &w.activeUpdates, w.UpdateBlock, 1)
+}
+
+// FullSubUpdateWorld sub updates every block in the world, giving the same result as SubUpdateWorld
+func (w *World) FullSubUpdateWorld() int {
+	return w.updatePass(allWorldIndices, &w.activeSubUpdates, w.SubUpdateBlock, 1)
+}
@@ -0,0 +1,20 @@
+package core
+
+// worldChanges finds the blocks changed between frames, so the caches built from the world
+// share one comparison of the world rather than each keeping its own copy
+type worldChanges struct {
+	blocks  [WorldSize]Block // the world at the last update
+	changed []int            // reused between updates
+}
+
+// Update returns the indices of the blocks changed since the last update
+func (c *worldChanges) Update(w *World) []int {
+	c.changed = c.changed[:0]
+	for i, b := range w.Blocks {
+		if b != c.blocks[i] {
+			c.changed = append(c.changed, i)
+		}
+	}
+	c.blocks = w.Blocks
+	return c.changed
+}
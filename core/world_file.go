@@ -0,0 +1,98 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// WorldFile is the JSON representation of a world, air blocks are omitted
+type WorldFile struct {
+	Rules  WorldRules
+	Blocks []WorldFileBlock
+}
+
+type WorldFileBlock struct {
+	Position Vec3
+	Type     string
+	State    json.RawMessage `json:",omitempty"`
+}
+
+func decodeBlockState[B Block](state json.RawMessage) (Block, error) {
+	var b B
+	if len(state) == 0 {
+		return b, nil
+	}
+	err := json.Unmarshal(state, &b)
+	return b, err
+}
+
+// blockDecoders maps each block's Type() to a function which reads its state
+var blockDecoders = map[string]func(state json.RawMessage) (Block, error){
+	"Air":           decodeBlockState[Air],
+	"Lever":         decodeBlockState[Lever],
+	"RedstoneBlock": decodeBlockState[RedstoneBlock],
+	"RedstoneLamp":  decodeBlockState[RedstoneLamp],
+	"RedstoneTorch": decodeBlockState[RedstoneTorch],
+	"WoolBlock":     decodeBlockState[WoolBlock],
+}
+
+// encodeBlock returns the type and state of a block, the state is nil for blocks without any
+func encodeBlock(b Block) (string, json.RawMessage, error) {
+	if _, isKnown := blockDecoders[b.Type()]; !isKnown {
+		return "", nil, fmt.Errorf("cannot encode block type %s", b.Type())
+	}
+	state, err := json.Marshal(b)
+	if err != nil {
+		return "", nil, fmt.Errorf("error encoding %s: %w", b.Type(), err)
+	}
+	if string(state) == "{}" {
+		state = nil
+	}
+	return b.Type(), state, nil
+}
+
+func decodeBlock(blockType string, state json.RawMessage) (Block, error) {
+	decode, isKnown := blockDecoders[blockType]
+	if !isKnown {
+		return nil, fmt.Errorf("unknown block type %s", blockType)
+	}
+	b, err := decode(state)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", blockType, err)
+	}
+	return b, nil
+}
+
+func EncodeWorld(w *World) ([]byte, error) {
+	file := WorldFile{Rules: w.Rules, Blocks: []WorldFileBlock{}}
+	for i, b := range w.Blocks {
+		if isAir(b) {
+			continue
+		}
+		blockType, state, err := encodeBlock(b)
+		if err != nil {
+			return nil, err
+		}
+		file.Blocks = append(file.Blocks, WorldFileBlock{w.GetPosition(i), blockType, state})
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+func DecodeWorld(data []byte) (World, error) {
+	var file WorldFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return World{}, fmt.Errorf("error reading world JSON: %w", err)
+	}
+
+	w := World{Rules: file.Rules}
+	for _, fb := range file.Blocks {
+		b, err := decodeBlock(fb.Type, fb.State)
+		if err != nil {
+			return World{}, fmt.Errorf("block at %v: %w", fb.Position, err)
+		}
+		if !w.SetBlock(fb.Position, b) {
+			return World{}, fmt.Errorf("%s at %v is outside the world", fb.Type, fb.Position)
+		}
+	}
+	return w, nil
+}
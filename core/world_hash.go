@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+func fnvAdd(h uint64, x uint64) uint64 {
+	return (h ^ x) * fnvPrime64
+}
+
+func fnvString(s string) uint64 {
+	h := fnvOffset64
+	for i := 0; i < len(s); i++ {
+		h = fnvAdd(h, uint64(s[i]))
+	}
+	return h
+}
+
+// formatting a block is slow, so the hash of each block state is cached. Each state is only
+// stored once, so the cache is a sync.Map which is read without locking.
+// Blocks must be comparable to be used as map keys.
+var blockStateHashes sync.Map
+
+func blockStateHash(b Block) uint64 {
+	if h, ok := blockStateHashes.Load(b); ok {
+		return h.(uint64)
+	}
+	h := fnvString(fmt.Sprintf("%T%+v", b, b))
+	blockStateHashes.Store(b, h)
+	return h
+}
+
+// blockHash is the hash of block b at index i, 0 for air. A world's hash is the sum of its
+// blocks' hashes, so it can be updated from only the blocks that change.
+func blockHash(i int, b Block) uint64 {
+	if isAir(b) {
+		return 0
+	}
+	return fnvAdd(fnvAdd(fnvOffset64, uint64(i)), blockStateHash(b))
+}
+
+func isAir(b Block) bool {
+	if b == nil {
+		return true
+	}
+	_, isAir := b.(Air)
+	return isAir
+}
+
+// Hash returns a hash of every block in the world, equal worlds have equal hashes
+func (w *World) Hash() uint64 {
+	if w.isHashTracked {
+		return w.hash
+	}
+	return w.RegionHash(Vec3{}, Vec3{X: WorldWidth, Y: WorldHeight, Z: WorldDepth})
+}
+
+// trackHash makes SetBlock keep the world's hash up to date, so Hash does not read every block
+func (w *World) trackHash(enabled bool) {
+	if enabled && !w.isHashTracked {
+		w.hash = w.RegionHash(Vec3{}, Vec3{X: WorldWidth, Y: WorldHeight, Z: WorldDepth})
+	}
+	w.isHashTracked = enabled
+}
+
+// RegionHash returns a hash of the blocks from start (inclusive) to end (exclusive)
+func (w *World) RegionHash(start Vec3, end Vec3) uint64 {
+	var h uint64
+	for z := max(0, min(start.Z, WorldDepth)); z < max(0, min(end.Z, WorldDepth)); z++ {
+		for y := max(0, min(start.Y, WorldHeight)); y < max(0, min(end.Y, WorldHeight)); y++ {
+			for x := max(0, min(start.X, WorldWidth)); x < max(0, min(end.X, WorldWidth)); x++ {
+				i := w.GetIndex(Vec3{X: x, Y: y, Z: z})
+				h += blockHash(i, w.Blocks[i])
+			}
+		}
+	}
+	return h
+}
@@ -0,0 +1,561 @@
+package core
+
+import (
+	"container/heap"
+	"testing"
+)
+
+func TestQuasiConnectivity(t *testing.T) {
+	w := World{}
+	p := Vec3{X: 5, Y: 5, Z: 5}
+	// a redstone block diagonally above p powers the block above p, but not p
+	w.SetBlock(p.Move(Up).Move(Left), RedstoneBlock{})
+
+	if IsQuasiConnectedPowered(p, &w) {
+		t.Errorf("Expected %v to be unpowered without quasi-connectivity", p)
+	}
+
+	w.SetQuasiConnectivity(true)
+	if !IsQuasiConnectedPowered(p, &w) {
+		t.Errorf("Expected %v to be powered with quasi-connectivity", p)
+	}
+	if IsInputPowered(p, &w) {
+		t.Errorf("Expected %v to not be directly powered", p)
+	}
+}
+
+func TestTickSchedulerTorchDelay(t *testing.T) {
+	w := World{}
+	wool := Vec3{X: 5, Y: 5, Z: 5}
+	lever := wool.Move(Left)
+	torch := wool.Move(Up)
+	w.SetBlock(wool, WoolBlock{Cyan, None})
+	w.SetBlock(lever, Lever{Direction: Left, IsOn: false})
+	w.SetBlock(torch, RedstoneTorch{Direction: Up, IsPowered: true})
+
+	s := TickScheduler{}
+	s.UpdateWorld(&w)
+	toggleLever(lever, &w)
+
+	// the wool is powered instantly, the torch turns off two ticks later
+	for i, expected := range []bool{true, true, false, false} {
+		s.UpdateWorld(&w)
+		if wool := w.GetBlock(wool).(WoolBlock); wool.InputPowerType != Strong {
+			t.Errorf("Tick %d: expected wool to be strongly powered", s.Tick)
+		}
+		if w.GetBlock(torch).(RedstoneTorch).IsPowered != expected {
+			t.Errorf("Tick %d (%d after toggle): expected torch powered to be %v", s.Tick, i+1, expected)
+		}
+	}
+}
+
+func TestTickSchedulerPriority(t *testing.T) {
+	s := TickScheduler{}
+	s.ScheduleTick(Vec3{X: 1}, 2, LowPriority)
+	s.ScheduleTick(Vec3{X: 2}, 2, HighPriority)
+	s.ScheduleTick(Vec3{X: 3}, 1, LowPriority)
+	s.ScheduleTick(Vec3{X: 4}, 2, HighPriority)
+	s.ScheduleTick(Vec3{X: 4}, 1, HighPriority) // already scheduled
+
+	expected := []int{3, 2, 4, 1}
+	for i, x := range expected {
+		tick := heap.Pop(&s.ticks).(ScheduledTick)
+		if tick.Position.X != x {
+			t.Errorf("Tick %d: expected position %d, got %d", i, x, tick.Position.X)
+		}
+	}
+	if len(s.ticks) != 0 {
+		t.Errorf("Expected duplicate tick to be ignored")
+	}
+}
+
+func TestTickSchedulerBlockEvent(t *testing.T) {
+	w := World{}
+	wool := Vec3{X: 5, Y: 5, Z: 5}
+	lever := wool.Move(Left)
+	torch := wool.Move(Up)
+	w.SetBlock(wool, WoolBlock{Cyan, None})
+	w.SetBlock(torch, RedstoneTorch{Direction: Up, IsPowered: true})
+
+	s := TickScheduler{}
+	s.UpdateWorld(&w)
+	s.AddBlockEvent(lever, Lever{Direction: Left, IsOn: true})
+	s.UpdateWorld(&w)
+	if s.NumBlockEvents != 1 {
+		t.Errorf("Expected 1 block event, got %d", s.NumBlockEvents)
+	}
+	if _, isLever := w.GetBlock(lever).(Lever); !isLever {
+		t.Fatalf("Expected the block event to place a lever")
+	}
+	// the event notifies the lever's neighbours like any other change made by the scheduler
+	if wool := w.GetBlock(wool).(WoolBlock); wool.InputPowerType != Strong {
+		t.Errorf("Expected wool to be strongly powered by the placed lever")
+	}
+	for i := 0; i < 2; i++ {
+		s.UpdateWorld(&w)
+	}
+	if w.GetBlock(torch).(RedstoneTorch).IsPowered {
+		t.Errorf("Expected torch to turn off two ticks after the lever was placed")
+	}
+	if s.NumBlockEvents != 0 {
+		t.Errorf("Expected the block event to only run once")
+	}
+}
+
+func stepTestWorld(w *World, full bool) {
+	for i := 0; i < 50; i++ {
+		var numSubUpdates int
+		if full {
+			numSubUpdates = w.FullSubUpdateWorld()
+		} else {
+			numSubUpdates = w.SubUpdateWorld()
+		}
+		if numSubUpdates == 0 {
+			break
+		}
+	}
+	if full {
+		w.FullUpdateWorld()
+	} else {
+		w.UpdateWorld()
+	}
+}
+
+func TestActiveUpdatesMatchFullSweep(t *testing.T) {
+	active, full := World{}, World{}
+	CreateWorld(&active)
+	CreateWorld(&full)
+
+	levers := []Vec3{{X: 2, Y: 2, Z: 2}, {X: 12, Y: 2, Z: 2}, {X: 2, Y: 2, Z: 13}}
+	for step := 0; step < 64; step++ {
+		if step%5 == 3 {
+			lever := levers[step%len(levers)]
+			toggleLever(lever, &active)
+			toggleLever(lever, &full)
+		}
+		stepTestWorld(&active, false)
+		stepTestWorld(&full, true)
+		if active.Blocks != full.Blocks {
+			t.Fatalf("Step %d: active update world differs from full sweep", step)
+		}
+	}
+}
+
+func stepTestWorldWithWorkers(w *World, workers int) {
+	for i := 0; i < 50; i++ {
+		if w.ParallelSubUpdateWorld(workers) == 0 {
+			break
+		}
+	}
+	w.ParallelUpdateWorld(workers)
+}
+
+// createBusyWorld fills the world with stacks of lever, wool, torch and lamp
+// so toggling every lever changes most of the world
+func createBusyWorld(world *World) []Vec3 {
+	var levers []Vec3
+	for x := 0; x < WorldWidth; x++ {
+		for z := 0; z < WorldDepth; z++ {
+			for y := 0; y+3 < WorldHeight; y += 4 {
+				lever := Vec3{X: x, Y: y, Z: z}
+				world.SetBlock(lever, Lever{Direction: Down, IsOn: false})
+				world.SetBlock(lever.Move(Up), WoolBlock{Color(x % 16), None})
+				world.SetBlock(lever.Move(Up).Move(Up), RedstoneTorch{Direction: Up, IsPowered: false})
+				world.SetBlock(lever.Move(Up).Move(Up).Move(Up), RedstoneLamp{InputPowerType: None})
+				levers = append(levers, lever)
+			}
+		}
+	}
+	return levers
+}
+
+func TestParallelUpdatesMatchSerial(t *testing.T) {
+	serial := World{}
+	levers := createBusyWorld(&serial)
+	CreateWorld(&serial)
+	parallel := [...]World{serial, serial}
+	workers := [...]int{4, 7}
+
+	for step := 0; step < 16; step++ {
+		if step%3 == 1 {
+			for _, lever := range levers[step%2:] {
+				toggleLever(lever, &serial)
+				for i := range parallel {
+					toggleLever(lever, &parallel[i])
+				}
+			}
+		}
+		stepTestWorldWithWorkers(&serial, 1)
+		for i := range parallel {
+			stepTestWorldWithWorkers(&parallel[i], workers[i])
+			if parallel[i].Blocks != serial.Blocks {
+				t.Fatalf("Step %d: world updated with %d workers differs from serial", step, workers[i])
+			}
+		}
+	}
+}
+
+// blinkingBlock changes on every sub update, so the sub updates never settle
+type blinkingBlock struct {
+	IsOn bool
+}
+
+func (b blinkingBlock) Type() string {
+	return "BlinkingBlock"
+}
+
+func (b blinkingBlock) SubUpdate(p Vec3, w *World) (Block, bool) {
+	b.IsOn = !b.IsOn
+	return b, true
+}
+
+func TestSubUpdateOscillation(t *testing.T) {
+	w := World{}
+	createBusyWorld(&w)
+	iterations, _, oscillation := w.SubUpdateWorldUntilStable(50, 1)
+	if oscillation != nil {
+		t.Fatalf("Expected sub updates to settle, got %+v", *oscillation)
+	}
+	if iterations >= 50 {
+		t.Errorf("Expected sub updates to settle before the limit, took %d iterations", iterations)
+	}
+
+	p := Vec3{X: 3, Y: 3, Z: 3}
+	w.SetBlock(p, blinkingBlock{})
+	iterations, _, oscillation = w.SubUpdateWorldUntilStable(50, 1)
+	if oscillation == nil {
+		t.Fatal("Expected an oscillation to be detected")
+	}
+	if iterations != 50 {
+		t.Errorf("Expected sub updates to run until the limit, ran %d iterations", iterations)
+	}
+	if oscillation.CycleLength != 2 {
+		t.Errorf("Expected a cycle length of 2, got %d", oscillation.CycleLength)
+	}
+	if len(oscillation.Positions) != 1 || oscillation.Positions[0] != p {
+		t.Errorf("Expected only %v to oscillate, got %v", p, oscillation.Positions)
+	}
+}
+
+func TestTrackedHashMatchesFullHash(t *testing.T) {
+	w := World{}
+	levers := createBusyWorld(&w)
+	full := w.Hash()
+	w.trackHash(true)
+	if w.Hash() != full {
+		t.Fatalf("Expected the tracked hash to start as the full hash")
+	}
+	for step := 0; step < 8; step++ {
+		toggleLever(levers[step%len(levers)], &w)
+		stepTestWorld(&w, false)
+		w.isHashTracked = false
+		full = w.Hash()
+		w.isHashTracked = true
+		if w.Hash() != full {
+			t.Fatalf("Step %d: tracked hash %x differs from the full hash %x", step, w.Hash(), full)
+		}
+	}
+}
+
+func TestPeriodDetector(t *testing.T) {
+	w := World{}
+	lever := Vec3{X: 1, Y: 1, Z: 1}
+	w.SetBlock(lever, Lever{Direction: Left, IsOn: false})
+	w.SetBlock(Vec3{X: 10, Y: 10, Z: 10}, WoolBlock{Cyan, None})
+
+	world := PeriodDetector{}
+	region := PeriodDetector{}
+	region.SetRegion(&WorldRegion{Min: Vec3{X: 8, Y: 8, Z: 8}, Max: Vec3{X: 12, Y: 12, Z: 12}})
+	for tick := 0; tick < 20; tick++ {
+		// toggling the lever every 3 ticks is a clock with a period of 6
+		if tick%3 == 0 {
+			toggleLever(lever, &w)
+		}
+		world.Record(tick, &w)
+		region.Record(tick, &w)
+	}
+	if world.Period != 6 || world.CycleStart != 0 {
+		t.Errorf("Expected a period of 6 from tick 0, got %d from tick %d", world.Period, world.CycleStart)
+	}
+	if world.IsStable {
+		t.Errorf("Expected a clock to not be stable")
+	}
+	if !region.IsStable || region.Period != 0 || region.CycleStart != 0 {
+		t.Errorf("Expected the region to be stable from tick 0, got period %d from tick %d", region.Period, region.CycleStart)
+	}
+
+	world.Reset()
+	if world.Record(0, &w) || world.Period != 0 || world.CycleStart != -1 {
+		t.Errorf("Expected no cycle after reset, got %d from tick %d", world.Period, world.CycleStart)
+	}
+	// a world that stops changing is stable, not a cycle with a period of 1
+	for tick := 1; tick < 4; tick++ {
+		if world.Record(tick, &w) {
+			t.Errorf("Tick %d: expected a static world to not be reported as a cycle", tick)
+		}
+	}
+	if !world.IsStable || world.Period != 0 || world.CycleStart != 0 {
+		t.Errorf("Expected the world to be stable from tick 0, got period %d from tick %d", world.Period, world.CycleStart)
+	}
+}
+
+func TestWorldFileRoundTrip(t *testing.T) {
+	w := World{}
+	CreateWorld(&w)
+	w.SetQuasiConnectivity(true)
+	stepTestWorld(&w, false)
+
+	data, err := EncodeWorld(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeWorld(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Blocks != w.Blocks || decoded.Rules != w.Rules {
+		t.Errorf("Expected the decoded world to equal the encoded world")
+	}
+
+	w.SetBlock(Vec3{}, blinkingBlock{})
+	if _, err := EncodeWorld(&w); err == nil {
+		t.Errorf("Expected an error encoding an unregistered block type")
+	}
+	if _, err := DecodeWorld([]byte(`{"Blocks": [{"Position": {"X": 16}, "Type": "Lever"}]}`)); err == nil {
+		t.Errorf("Expected an error decoding a block outside the world")
+	}
+}
+
+// recordTestInputLog records a session of inputs, returning its log and the world after each tick
+func recordTestInputLog(t *testing.T) (InputLog, [16][WorldSize]Block) {
+	recorded := Scene{GameState: Playing, SimulationWorkers: 1}
+	recorded.StartRecording()
+	lever := Vec3{X: 2, Y: 2, Z: 2}
+	inputs := map[int]InputAction{
+		2:  {Type: ToggleLeverAction, Position: lever},
+		3:  {Type: PlaceBlockAction, Position: Vec3{X: 8, Y: 2, Z: 8}, Block: RedstoneTorch{Direction: Up, IsPowered: false}},
+		5:  {Type: ToggleQuasiConnectivityAction},
+		6:  {Type: DestroyBlockAction, Position: Vec3{X: 8, Y: 0, Z: 8}},
+		8:  {Type: ToggleSimulationModeAction},
+		9:  {Type: ToggleLeverAction, Position: lever},
+		12: {Type: ToggleLeverAction, Position: lever},
+	}
+	var states [16][WorldSize]Block
+	for tick := range states {
+		if a, hasInput := inputs[tick]; hasInput {
+			recorded.Input(a)
+		}
+		Update(&recorded)
+		states[tick] = recorded.World.Blocks
+	}
+
+	data, err := EncodeInputLog(&recorded.InputLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputLog, err := DecodeInputLog(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputLog.Actions) != len(inputs) {
+		t.Fatalf("Expected %d recorded inputs, got %d", len(inputs), len(inputLog.Actions))
+	}
+	return inputLog, states
+}
+
+func TestInputLogReplay(t *testing.T) {
+	inputLog, states := recordTestInputLog(t)
+	replayed := Scene{GameState: Playing, SimulationWorkers: 1}
+	replayed.StartReplay(inputLog)
+	for tick := range states {
+		Update(&replayed)
+		if replayed.World.Blocks != states[tick] {
+			t.Fatalf("Tick %d: replayed world differs from the recorded world", tick)
+		}
+	}
+	if replayed.InputLog.IsReplaying() {
+		t.Errorf("Expected the replay to have finished")
+	}
+}
+
+func TestReplayRewind(t *testing.T) {
+	inputLog, states := recordTestInputLog(t)
+	replayed := Scene{GameState: Playing, SimulationWorkers: 1}
+	replayed.StartReplay(inputLog)
+	for tick := 0; tick < 8; tick++ {
+		Update(&replayed)
+	}
+	// rewinding past the inputs of ticks 3, 5 and 6 replays them again
+	if n := replayed.History.Rewind(&replayed, 6); n != 6 || replayed.World.Blocks != states[1] {
+		t.Fatalf("Expected to rewind 6 ticks to the world after tick 1, rewound %d", n)
+	}
+	for tick := 2; tick < len(states); tick++ {
+		Update(&replayed)
+		if replayed.World.Blocks != states[tick] {
+			t.Fatalf("Tick %d: world replayed after rewinding differs from the recorded world", tick)
+		}
+	}
+	if replayed.InputLog.IsReplaying() {
+		t.Errorf("Expected the replay to have finished")
+	}
+}
+
+func TestHistoryRewindAndBranch(t *testing.T) {
+	scene := Scene{GameState: Playing, SimulationWorkers: 1}
+	lever := Vec3{X: 2, Y: 2, Z: 2}
+	var states [20][WorldSize]Block
+	for tick := range states {
+		if tick%4 == 1 {
+			scene.Input(InputAction{Type: ToggleLeverAction, Position: lever})
+		}
+		Update(&scene)
+		states[tick] = scene.World.Blocks
+	}
+
+	if n := scene.History.Rewind(&scene, 5); n != 5 {
+		t.Fatalf("Expected to rewind 5 ticks, rewound %d", n)
+	}
+	if scene.Iteration != 15 || scene.World.Blocks != states[14] {
+		t.Errorf("Expected the world after tick 14 at iteration 15, got iteration %d", scene.Iteration)
+	}
+	if n := scene.History.Forward(&scene, 2); n != 2 || scene.World.Blocks != states[16] {
+		t.Errorf("Expected to forward 2 ticks to the world after tick 16, forwarded %d", n)
+	}
+
+	// stepping from the past gives the same result as the original timeline
+	scene.History.Forward(&scene, 3)
+	scene.History.Rewind(&scene, 20)
+	if scene.Iteration != 0 || scene.World.Blocks != (World{}).Blocks {
+		t.Fatalf("Expected the empty world at iteration 0, got iteration %d", scene.Iteration)
+	}
+	for tick := range states {
+		if tick%4 == 1 {
+			scene.Input(InputAction{Type: ToggleLeverAction, Position: lever})
+		}
+		Update(&scene)
+		if scene.World.Blocks != states[tick] {
+			t.Fatalf("Tick %d: world differs after rewinding", tick)
+		}
+	}
+
+	// stepping after a rewind replays the rewound ticks, keeping the inputs
+	scene.History.Rewind(&scene, 6)
+	for tick := len(states) - 6; tick < len(states); tick++ {
+		Update(&scene)
+		if scene.World.Blocks != states[tick] {
+			t.Fatalf("Tick %d: world differs from before the rewind after stepping", tick)
+		}
+	}
+	if numTicks, numUndone := scene.History.NumTicks(); numTicks != len(states) || numUndone != 0 {
+		t.Errorf("Expected %d ticks and none rewound after stepping forward, got %d and %d", len(states), numTicks, numUndone)
+	}
+	if len(scene.InputLog.Actions) != 5 {
+		t.Errorf("Expected stepping after a rewind to keep the 5 inputs, got %d", len(scene.InputLog.Actions))
+	}
+
+	// branching discards the future ticks and inputs
+	scene.History.Rewind(&scene, 10)
+	scene.Input(InputAction{Type: ToggleLeverAction, Position: lever})
+	if numTicks, numUndone := scene.History.NumTicks(); numTicks != 10 || numUndone != 0 {
+		t.Errorf("Expected 10 ticks and none rewound after branching, got %d and %d", numTicks, numUndone)
+	}
+	if n := scene.History.Forward(&scene, 1); n != 0 {
+		t.Errorf("Expected no ticks to forward after branching, forwarded %d", n)
+	}
+	// the inputs on ticks 1, 5 and 9 are kept
+	if len(scene.InputLog.Actions) != 4 {
+		t.Errorf("Expected 4 inputs after branching, got %d", len(scene.InputLog.Actions))
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	scene := Scene{GameState: Playing, SimulationWorkers: 1}
+	Update(&scene)
+	// the lamp next to the lever at (2, 2, 2) turns on when it is toggled
+	lamp := Vec3{X: 3, Y: 2, Z: 2}
+	scene.ToggleBreakpoint(BlockBreakpoint(lamp, BreakOnUnpowered))
+	scene.ToggleBreakpoint(Breakpoint{
+		Region:    WorldRegion{Vec3{X: 3, Y: 3, Z: 2}, Vec3{X: 4, Y: 8, Z: 3}},
+		BlockType: "RedstoneTorch",
+		Condition: BreakOnChange,
+	})
+	if !scene.ToggleBreakpoint(BlockBreakpoint(lamp, BreakOnPowered)) {
+		t.Fatal("Expected the breakpoint to be added")
+	}
+
+	for i := 0; i < 4; i++ {
+		Update(&scene)
+	}
+	if scene.BreakpointHit != nil || scene.GameState != Playing {
+		t.Fatalf("Expected no breakpoint to be hit, got %v", scene.BreakpointHit)
+	}
+
+	scene.Input(InputAction{Type: ToggleLeverAction, Position: Vec3{X: 2, Y: 2, Z: 2}})
+	Update(&scene)
+	hit := scene.BreakpointHit
+	if hit == nil || scene.GameState != Paused {
+		t.Fatal("Expected the lamp turning on to hit a breakpoint and pause")
+	}
+	if hit.Position != lamp || hit.Breakpoint.Condition != BreakOnPowered || hit.Iteration != 5 {
+		t.Errorf("Expected the lamp breakpoint at iteration 5, got %v", hit)
+	}
+
+	// the torch above the lamp turns off on the next tick
+	scene.GameState = Playing
+	Update(&scene)
+	if hit := scene.BreakpointHit; hit == nil || hit.Breakpoint.BlockType != "RedstoneTorch" {
+		t.Errorf("Expected the torch breakpoint to be hit, got %v", hit)
+	}
+}
+
+func TestLightMap(t *testing.T) {
+	w := World{}
+	lamp := Vec3{X: 8, Y: 8, Z: 8}
+	w.SetBlock(lamp, RedstoneLamp{InputPowerType: Strong})
+	wall := lamp.Move(Right).Move(Right)
+	w.SetBlock(wall, WoolBlock{White, None})
+
+	l := LightMap{}
+	changes := worldChanges{}
+	l.Update(&w, changes.Update(&w))
+	for _, c := range []struct {
+		p        Vec3
+		expected int
+	}{
+		{lamp, 15},
+		{lamp.Move(Left), 14},
+		{lamp.Move(Up).Move(Up).Move(Front), 12},
+		{wall, 0},              // opaque blocks are not lit
+		{wall.Move(Right), 10}, // light goes around the wall
+		{Vec3{X: 0, Y: 0, Z: 0}, 0},
+	} {
+		if level := l.Level(c.p); level != c.expected {
+			t.Errorf("Expected light level %d at %v, got %d", c.expected, c.p, level)
+		}
+	}
+
+	// incremental updates match calculating the light from scratch
+	torch := Vec3{X: 2, Y: 8, Z: 8}
+	for i, change := range []struct {
+		p     Vec3
+		block Block
+	}{
+		{torch, RedstoneTorch{Direction: Up, IsPowered: true}},
+		{lamp, RedstoneLamp{InputPowerType: None}},
+		{lamp.Move(Left), WoolBlock{White, None}},
+		{lamp, RedstoneLamp{InputPowerType: Strong}},
+		{wall, Air{}},
+		{torch, RedstoneTorch{Direction: Up, IsPowered: false}},
+	} {
+		w.SetBlock(change.p, change.block)
+		l.Update(&w, changes.Update(&w))
+		expected := LightMap{}
+		expected.Update(&w, nil)
+		if l.levels != expected.levels {
+			t.Fatalf("Change %d: expected the incremental light levels to match the full calculation", i)
+		}
+	}
+	if level := l.Level(lamp.Move(Left)); level != 0 {
+		t.Errorf("Expected the wool next to the lamp to be unlit, got %d", level)
+	}
+}
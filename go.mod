@@ -2,10 +2,12 @@ module project_two
 
 go 1.22.0
 
-require golang.org/x/image v0.21.0
+require (
+	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203
+	golang.org/x/image v0.21.0
+)
 
 require (
-	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
 )
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"project_two/core2"
 	"time"
 )
@@ -33,5 +34,8 @@ func main() {
 	// core.StartMemProfile()
 	// core.RunEngineWrapper()
 	// core.RunOBJTest()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	core2.Main()
 }